@@ -0,0 +1,330 @@
+// See the LICENSE file for license details.
+
+package cert_manager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// ErrorHandler is a callback to call if an error is encountered while reloading certificates.
+type ErrorHandler func(err error)
+
+// CertificateFiles specifies the location of a PEM-encoded certificate chain and its private key.
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Options specifies the certificate manager creation options.
+type Options struct {
+	// Certificates is a list of certificate and key file pairs to load.
+	Certificates []CertificateFiles
+
+	// Directory, if defined, is scanned for certificate and key pairs. Each "name.crt" or "name.pem" file is
+	// paired with a "name.key" file located in the same directory.
+	Directory string
+
+	// PollInterval is the interval used to check the files for changes. Defaults to 1 minute. Set a negative
+	// value to disable polling and rely on manual calls to Reload.
+	PollInterval time.Duration
+
+	// A callback to call if an error is encountered while reloading certificates. If an error occurs, the
+	// previously loaded certificates are kept.
+	ErrorHandler ErrorHandler
+}
+
+// Manager loads TLS certificates from disk and swaps them atomically when the files change.
+type Manager struct {
+	certificates []CertificateFiles
+	directory    string
+	errorHandler ErrorHandler
+
+	store      atomic.Pointer[certificateStore]
+	reloadMtx  sync.Mutex
+	fileStamps map[string]fileStamp
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+type certificateStore struct {
+	exact      map[string]*tls.Certificate
+	wildcard   map[string]*tls.Certificate
+	defaultCrt *tls.Certificate
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	DefaultPollInterval = time.Minute
+)
+
+// -----------------------------------------------------------------------------
+
+// New creates a new certificate manager and loads the certificates. If polling is enabled, a background
+// goroutine checks the files periodically until Stop is called.
+func New(opts Options) (*Manager, error) {
+	if len(opts.Certificates) == 0 && len(opts.Directory) == 0 {
+		return nil, errors.New("no certificates or directory were specified")
+	}
+
+	pollInterval := opts.PollInterval
+	if pollInterval == time.Duration(0) {
+		pollInterval = DefaultPollInterval
+	}
+
+	m := &Manager{
+		certificates: append(make([]CertificateFiles, 0, len(opts.Certificates)), opts.Certificates...),
+		directory:    opts.Directory,
+		errorHandler: opts.ErrorHandler,
+		stopCh:       make(chan struct{}),
+	}
+
+	// Do initial load
+	err := m.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	// Start polling
+	if pollInterval > 0 {
+		m.wg.Add(1)
+		go m.pollLoop(pollInterval)
+	}
+
+	// Done
+	return m, nil
+}
+
+// Stop stops watching the certificate files for changes.
+func (m *Manager) Stop() {
+	select {
+	case <-m.stopCh:
+	default:
+		close(m.stopCh)
+	}
+	m.wg.Wait()
+}
+
+// Reload forces a reload of all the certificates. The new set replaces the current one only if all the
+// certificates are loaded successfully.
+func (m *Manager) Reload() error {
+	m.reloadMtx.Lock()
+	defer m.reloadMtx.Unlock()
+
+	pairs, err := m.collectPairs()
+	if err != nil {
+		return err
+	}
+
+	stamps := make(map[string]fileStamp)
+	store := &certificateStore{
+		exact:    make(map[string]*tls.Certificate),
+		wildcard: make(map[string]*tls.Certificate),
+	}
+	for _, pair := range pairs {
+		var crt tls.Certificate
+
+		for _, filename := range []string{pair.CertFile, pair.KeyFile} {
+			stamps[filename], err = getFileStamp(filename)
+			if err != nil {
+				return err
+			}
+		}
+
+		crt, err = tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load certificate %v [err=%w]", pair.CertFile, err)
+		}
+		if crt.Leaf == nil {
+			crt.Leaf, err = x509.ParseCertificate(crt.Certificate[0])
+			if err != nil {
+				return fmt.Errorf("unable to parse certificate %v [err=%w]", pair.CertFile, err)
+			}
+		}
+
+		store.add(&crt)
+	}
+
+	// Swap certificates
+	m.store.Store(store)
+	m.fileStamps = stamps
+
+	// Done
+	return nil
+}
+
+// GetCertificate returns the certificate that matches the server name sent by the client. It is meant to be
+// used as the tls.Config.GetCertificate callback. If no certificate matches, the first loaded one is returned.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	store := m.store.Load()
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if len(name) > 0 {
+		if crt, ok := store.exact[name]; ok {
+			return crt, nil
+		}
+		if dotIdx := strings.IndexByte(name, '.'); dotIdx > 0 {
+			if crt, ok := store.wildcard[name[dotIdx+1:]]; ok {
+				return crt, nil
+			}
+		}
+	}
+	if store.defaultCrt == nil {
+		return nil, errors.New("no certificate available")
+	}
+	return store.defaultCrt, nil
+}
+
+// TLSConfig returns a new TLS configuration that uses this manager to select the certificates.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (m *Manager) pollLoop(interval time.Duration) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopCh:
+			return
+
+		case <-ticker.C:
+			if m.hasChanged() {
+				err := m.Reload()
+				if err != nil && m.errorHandler != nil {
+					m.errorHandler(err)
+				}
+			}
+		}
+	}
+}
+
+func (m *Manager) hasChanged() bool {
+	pairs, err := m.collectPairs()
+	if err != nil {
+		return true
+	}
+
+	m.reloadMtx.Lock()
+	defer m.reloadMtx.Unlock()
+
+	count := 0
+	for _, pair := range pairs {
+		for _, filename := range []string{pair.CertFile, pair.KeyFile} {
+			stamp, err2 := getFileStamp(filename)
+			if err2 != nil {
+				return true
+			}
+			if prevStamp, ok := m.fileStamps[filename]; !ok || prevStamp != stamp {
+				return true
+			}
+			count += 1
+		}
+	}
+
+	// Check if some pair was removed
+	return count != len(m.fileStamps)
+}
+
+func (m *Manager) collectPairs() ([]CertificateFiles, error) {
+	pairs := append(make([]CertificateFiles, 0, len(m.certificates)), m.certificates...)
+
+	if len(m.directory) > 0 {
+		entries, err := os.ReadDir(m.directory)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read certificates directory [err=%w]", err)
+		}
+
+		names := make([]string, 0)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			ext := filepath.Ext(name)
+			if ext != ".crt" && ext != ".pem" {
+				continue
+			}
+
+			keyFile := filepath.Join(m.directory, strings.TrimSuffix(name, ext)+".key")
+			if _, err = os.Stat(keyFile); err != nil {
+				continue
+			}
+			pairs = append(pairs, CertificateFiles{
+				CertFile: filepath.Join(m.directory, name),
+				KeyFile:  keyFile,
+			})
+		}
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+
+	// Done
+	return pairs, nil
+}
+
+func (store *certificateStore) add(crt *tls.Certificate) {
+	names := crt.Leaf.DNSNames
+	if len(names) == 0 && len(crt.Leaf.Subject.CommonName) > 0 {
+		names = []string{crt.Leaf.Subject.CommonName}
+	}
+
+	for _, name := range names {
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		if strings.HasPrefix(name, "*.") {
+			if _, ok := store.wildcard[name[2:]]; !ok {
+				store.wildcard[name[2:]] = crt
+			}
+		} else {
+			if _, ok := store.exact[name]; !ok {
+				store.exact[name] = crt
+			}
+		}
+	}
+
+	if store.defaultCrt == nil {
+		store.defaultCrt = crt
+	}
+}
+
+func getFileStamp(filename string) (fileStamp, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}, nil
+}
@@ -0,0 +1,114 @@
+// See the LICENSE file for license details.
+
+package cert_manager_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mxmauro/go-webserver/v2/cert_manager"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestCertManager(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestCertificate(t, dir, "default", "default.test", 1)
+	writeTestCertificate(t, dir, "wildcard", "*.example.test", 2)
+
+	m, err := cert_manager.New(cert_manager.Options{
+		Directory:    dir,
+		PollInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("unable to create certificate manager [%v]", err)
+	}
+	defer m.Stop()
+
+	checkSerial := func(serverName string, expected int64) {
+		crt, err2 := m.GetCertificate(&tls.ClientHelloInfo{
+			ServerName: serverName,
+		})
+		if err2 != nil {
+			t.Fatalf("unable to get certificate for %v [%v]", serverName, err2)
+		}
+		if crt.Leaf.SerialNumber.Int64() != expected {
+			t.Fatalf("unexpected certificate for %v [got:%v / expected:%v]", serverName, crt.Leaf.SerialNumber, expected)
+		}
+	}
+
+	// Check SNI selection
+	checkSerial("default.test", 1)
+	checkSerial("api.example.test", 2)
+	checkSerial("unknown.test", 1)
+
+	// Rotate the wildcard certificate and reload
+	writeTestCertificate(t, dir, "wildcard", "*.example.test", 3)
+	err = m.Reload()
+	if err != nil {
+		t.Fatalf("unable to reload certificates [%v]", err)
+	}
+	checkSerial("api.example.test", 3)
+
+	// A broken key must not replace the current certificates
+	err = os.WriteFile(filepath.Join(dir, "wildcard.key"), []byte("broken"), 0o600)
+	if err != nil {
+		t.Fatalf("unable to write key [%v]", err)
+	}
+	if m.Reload() == nil {
+		t.Fatalf("reload succeeded with an invalid key")
+	}
+	checkSerial("api.example.test", 3)
+}
+
+// -----------------------------------------------------------------------------
+
+func writeTestCertificate(t *testing.T, dir string, name string, dnsName string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key [%v]", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{
+			CommonName: dnsName,
+		},
+		DNSNames:  []string{dnsName},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate [%v]", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to encode key [%v]", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	}), 0o600)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: keyDer,
+		}), 0o600)
+	}
+	if err != nil {
+		t.Fatalf("unable to write certificate [%v]", err)
+	}
+}
@@ -0,0 +1,76 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"net"
+	"runtime"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// connTracker keeps the connection served by each goroutine of the fasthttp server. fasthttp reads and handles
+// all the requests of a connection in the goroutine that serves it, so the hooks that only receive the request
+// header, like HeaderReceived and ContinueHandler, use it to know the connection the request came from.
+type connTracker struct {
+	byGoroutine sync.Map // goroutine id -> net.Conn
+	byConn      sync.Map // net.Conn -> goroutine id
+}
+
+// -----------------------------------------------------------------------------
+
+func (t *connTracker) onConnState(conn net.Conn, state fasthttp.ConnState) {
+	switch state {
+	case fasthttp.StateActive:
+		// The goroutine is only looked up on the first request because the rest are served by the same one
+		if _, ok := t.byConn.Load(conn); !ok {
+			id := currentGoroutineID()
+			t.byConn.Store(conn, id)
+			t.byGoroutine.Store(id, conn)
+		}
+
+	case fasthttp.StateHijacked, fasthttp.StateClosed:
+		if id, ok := t.byConn.LoadAndDelete(conn); ok {
+			t.byGoroutine.CompareAndDelete(id, conn)
+		}
+	}
+}
+
+// current returns the connection served by the calling goroutine or nil if it is not serving any.
+func (t *connTracker) current() net.Conn {
+	conn, ok := t.byGoroutine.Load(currentGoroutineID())
+	if !ok {
+		return nil
+	}
+	return conn.(net.Conn)
+}
+
+// -----------------------------------------------------------------------------
+
+func currentGoroutineID() uint64 {
+	var buf [64]byte
+
+	// The trace starts with "goroutine <id> ["
+	n := runtime.Stack(buf[:], false)
+	id := uint64(0)
+	for _, ch := range buf[len("goroutine "):n] {
+		if ch < '0' || ch > '9' {
+			break
+		}
+		id = id*10 + uint64(ch-'0')
+	}
+	return id
+}
+
+// remoteIP returns the IP address of the given connection's peer like fasthttp.RequestCtx.RemoteIP does.
+func remoteIP(conn net.Conn) net.IP {
+	if conn != nil {
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			return addr.IP
+		}
+	}
+	return net.IPv4zero
+}
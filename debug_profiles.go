@@ -41,6 +41,10 @@ var debugProfiles []debugProfile
 
 // ServeDebugProfiles adds the GO runtime profile handlers to a web server
 func (srv *Server) ServeDebugProfiles(basePath string, middlewares ...HandlerFunc) {
+	srv.defaultRoutes.serveDebugProfiles(basePath, nil, middlewares)
+}
+
+func (rt *routeTable) serveDebugProfiles(basePath string, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc) {
 	// Prepare debug profile array if not done yet
 	if debugProfiles == nil {
 		for _, profile := range pprof.Profiles() {
@@ -77,11 +81,11 @@ func (srv *Server) ServeDebugProfiles(basePath string, middlewares ...HandlerFun
 	}
 
 	// Add index page
-	srv.GET(basePath, onDebugProfilesIndex, middlewares...)
+	rt.addRoute("GET", basePath, onDebugProfilesIndex, groupMiddlewares, middlewares)
 
 	// Add profile pages
 	for _, p := range debugProfiles {
-		srv.GET(basePath+"/"+p.name, p.handler, middlewares...)
+		rt.addRoute("GET", basePath+"/"+p.name, p.handler, groupMiddlewares, middlewares)
 	}
 }
 
@@ -0,0 +1,263 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"sync"
+)
+
+// -----------------------------------------------------------------------------
+
+// EventHubOptions sets optional parameters for NewEventHub.
+type EventHubOptions struct {
+	// BufferSize is the number of events queued for each subscriber. Subscribers that fall behind are dropped.
+	// Defaults to 64.
+	BufferSize int
+
+	// ReplaySize is the number of events, per topic, kept to resume the streams of reconnecting clients.
+	// Defaults to 0, which disables the replay.
+	ReplaySize int
+}
+
+// EventHub fans out server-sent events published on topics to their subscribers.
+type EventHub struct {
+	mtx        sync.Mutex
+	bufferSize int
+	replaySize int
+	topics     map[string]*eventTopic
+	closed     bool
+}
+
+// EventSubscription receives the events published on one or more topics of a hub.
+type EventSubscription struct {
+	hub    *EventHub
+	topics []string
+	ch     chan Event
+	closed bool
+}
+
+type eventTopic struct {
+	subscribers map[*EventSubscription]struct{}
+	history     []Event
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultEventHubBufferSize = 64
+)
+
+// -----------------------------------------------------------------------------
+
+// NewEventHub creates a new event hub.
+func NewEventHub(opts ...EventHubOptions) *EventHub {
+	var o EventHubOptions
+
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultEventHubBufferSize
+	}
+	if o.ReplaySize < 0 {
+		o.ReplaySize = 0
+	}
+
+	return &EventHub{
+		bufferSize: o.BufferSize,
+		replaySize: o.ReplaySize,
+		topics:     make(map[string]*eventTopic),
+	}
+}
+
+// Publish sends an event to all the subscribers of the topic and returns the number of subscribers that
+// received it. Subscribers whose buffer is full are dropped.
+func (h *EventHub) Publish(topic string, ev Event) int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return 0
+	}
+
+	t := h.topics[topic]
+	if t == nil {
+		if h.replaySize == 0 || len(ev.ID) == 0 {
+			return 0
+		}
+		t = h.addTopic(topic)
+	}
+
+	// Keep the event to replay it to reconnecting clients
+	if h.replaySize > 0 && len(ev.ID) > 0 {
+		if len(t.history) == h.replaySize {
+			copy(t.history, t.history[1:])
+			t.history = t.history[:len(t.history)-1]
+		}
+		t.history = append(t.history, ev)
+	}
+
+	delivered := 0
+	for sub := range t.subscribers {
+		select {
+		case sub.ch <- ev:
+			delivered += 1
+		default:
+			// Slow consumer
+			h.unsubscribe(sub)
+		}
+	}
+
+	// Done
+	return delivered
+}
+
+// Subscribe creates a new subscription to the specified topics.
+func (h *EventHub) Subscribe(topics ...string) *EventSubscription {
+	return h.Resume("", topics...)
+}
+
+// Resume creates a new subscription to the specified topics and queues the events published after the one with
+// the specified id. If the id is empty or is not found in the replay history, no event is queued.
+func (h *EventHub) Resume(lastEventID string, topics ...string) *EventSubscription {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	// Collect the events to replay
+	var replay []Event
+	if len(lastEventID) > 0 {
+		for _, topic := range topics {
+			if t := h.topics[topic]; t != nil {
+				for idx := len(t.history) - 1; idx >= 0; idx-- {
+					if t.history[idx].ID == lastEventID {
+						replay = append(replay, t.history[idx+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	sub := &EventSubscription{
+		hub:    h,
+		topics: topics,
+		ch:     make(chan Event, h.bufferSize+len(replay)),
+	}
+	for _, ev := range replay {
+		sub.ch <- ev
+	}
+
+	if h.closed {
+		sub.closed = true
+		close(sub.ch)
+		return sub
+	}
+
+	for _, topic := range topics {
+		t := h.topics[topic]
+		if t == nil {
+			t = h.addTopic(topic)
+		}
+		t.subscribers[sub] = struct{}{}
+	}
+
+	// Done
+	return sub
+}
+
+// Stream starts a text/event-stream response that sends the events published on the specified topics to the
+// client until it disconnects, the subscriber is dropped, the hub is closed or the server is stopped. The
+// Last-Event-ID header sent by reconnecting clients is used to resume the stream.
+func (h *EventHub) Stream(req *RequestContext, topics []string, opts ...EventStreamOptions) {
+	sub := h.Resume(string(req.ctx.Request.Header.Peek("Last-Event-ID")), topics...)
+
+	req.EventStream(func(es *EventStream) {
+		defer sub.Close()
+
+		for {
+			select {
+			case ev, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				if es.Send(ev) != nil {
+					return
+				}
+
+			case <-es.Context().Done():
+				return
+			}
+		}
+	}, opts...)
+}
+
+// Subscribers returns the number of subscribers of a topic.
+func (h *EventHub) Subscribers(topic string) int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if t := h.topics[topic]; t != nil {
+		return len(t.subscribers)
+	}
+	return 0
+}
+
+// Close closes all the subscriptions. Further events are discarded.
+func (h *EventHub) Close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for _, t := range h.topics {
+		for sub := range t.subscribers {
+			h.unsubscribe(sub)
+		}
+	}
+	h.topics = make(map[string]*eventTopic)
+}
+
+// Events returns the channel where the events are delivered. The channel is closed when the subscription is
+// closed or dropped.
+func (sub *EventSubscription) Events() <-chan Event {
+	return sub.ch
+}
+
+// Close cancels the subscription.
+func (sub *EventSubscription) Close() {
+	sub.hub.mtx.Lock()
+	defer sub.hub.mtx.Unlock()
+
+	sub.hub.unsubscribe(sub)
+}
+
+// -----------------------------------------------------------------------------
+
+func (h *EventHub) addTopic(topic string) *eventTopic {
+	t := &eventTopic{
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+	h.topics[topic] = t
+	return t
+}
+
+// unsubscribe removes the subscription from its topics and closes its channel. The hub lock must be held.
+func (h *EventHub) unsubscribe(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+
+	for _, topic := range sub.topics {
+		if t := h.topics[topic]; t != nil {
+			delete(t.subscribers, sub)
+			if len(t.subscribers) == 0 && len(t.history) == 0 {
+				delete(h.topics, topic)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// EventStreamHandler defines a function that sends server-sent events to the client. The stream is closed when
+// the function returns.
+type EventStreamHandler func(es *EventStream)
+
+// EventStreamOptions sets optional parameters for RequestContext.EventStream.
+type EventStreamOptions struct {
+	// KeepAliveInterval is the interval between comments sent to keep the connection alive and detect
+	// disconnected clients. Defaults to 15 seconds. Set a negative value to disable them.
+	KeepAliveInterval time.Duration
+
+	// Retry, if set, is sent to the client at the beginning of the stream as the reconnection time to use.
+	Retry time.Duration
+}
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is an optional identifier. The client sends the last received one in the Last-Event-ID header when
+	// it reconnects.
+	ID string
+
+	// Name is the optional event type. Clients use "message" if not set.
+	Name string
+
+	// Data is the payload of the event. It can contain multiple lines.
+	Data string
+
+	// Retry, if set, changes the reconnection time of the client.
+	Retry time.Duration
+}
+
+// EventStream sends server-sent events to a client.
+type EventStream struct {
+	mtx          sync.Mutex
+	w            *bufio.Writer
+	conn         net.Conn
+	writeTimeout time.Duration
+	ctx          context.Context
+	cancelCtx    context.CancelFunc
+	lastEventID  string
+	closed       bool
+	err          error
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultEventStreamKeepAliveInterval = 15 * time.Second
+)
+
+var (
+	ErrEventStreamClosed = errors.New("event stream closed")
+
+	errInvalidEventField = errors.New("event id and name cannot contain line breaks")
+)
+
+// -----------------------------------------------------------------------------
+
+// EventStream starts a text/event-stream response and calls the provided function to send the events. The
+// function is called after the handler returns, so it must not access the request context. Use
+// EventStream.LastEventID to resume from the last event received by a reconnecting client.
+//
+// The stream's context is canceled when the client disconnects, a keep-alive comment cannot be sent or the server
+// is being stopped.
+func (req *RequestContext) EventStream(fn EventStreamHandler, opts ...EventStreamOptions) {
+	var o EventStreamOptions
+
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.KeepAliveInterval == 0 {
+		o.KeepAliveInterval = defaultEventStreamKeepAliveInterval
+	}
+
+	lastEventID := string(req.ctx.Request.Header.Peek("Last-Event-ID"))
+	serverDone := req.ctx.Done()
+	conn := req.ctx.Conn()
+	writeTimeout := req.srv.fastserver.WriteTimeout
+
+	req.ctx.Response.Header.SetContentType("text/event-stream")
+	req.ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-cache")
+	req.ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	req.ctx.SetStatusCode(fasthttp.StatusOK)
+
+	req.ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		es := &EventStream{
+			w:            w,
+			conn:         conn,
+			writeTimeout: writeTimeout,
+			lastEventID:  lastEventID,
+		}
+		es.ctx, es.cancelCtx = context.WithCancel(context.Background())
+		defer es.close()
+
+		// Send the response headers along with the reconnection time, if any
+		if o.Retry > 0 {
+			_ = es.write("retry: " + strconv.FormatInt(o.Retry.Milliseconds(), 10) + "\n\n")
+		} else {
+			_ = es.write("")
+		}
+
+		go es.watch(serverDone, o.KeepAliveInterval)
+
+		fn(es)
+	})
+}
+
+// Context returns a context that is canceled when the stream is closed.
+func (es *EventStream) Context() context.Context {
+	return es.ctx
+}
+
+// LastEventID returns the value of the Last-Event-ID header sent by a reconnecting client.
+func (es *EventStream) LastEventID() string {
+	return es.lastEventID
+}
+
+// Send sends an event to the client.
+func (es *EventStream) Send(ev Event) error {
+	if strings.ContainsAny(ev.ID, "\r\n") || strings.ContainsAny(ev.Name, "\r\n") {
+		return errInvalidEventField
+	}
+
+	sb := strings.Builder{}
+	if len(ev.ID) > 0 {
+		_, _ = sb.WriteString("id: " + ev.ID + "\n")
+	}
+	if len(ev.Name) > 0 {
+		_, _ = sb.WriteString("event: " + ev.Name + "\n")
+	}
+	if ev.Retry > 0 {
+		_, _ = sb.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range splitEventLines(ev.Data) {
+		_, _ = sb.WriteString("data: " + line + "\n")
+	}
+	_, _ = sb.WriteString("\n")
+
+	return es.write(sb.String())
+}
+
+// SendData sends an unnamed event with the specified payload.
+func (es *EventStream) SendData(data string) error {
+	return es.Send(Event{
+		Data: data,
+	})
+}
+
+// Comment sends a comment. Clients ignore them.
+func (es *EventStream) Comment(text string) error {
+	sb := strings.Builder{}
+	for _, line := range splitEventLines(text) {
+		_, _ = sb.WriteString(": " + line + "\n")
+	}
+	_, _ = sb.WriteString("\n")
+
+	return es.write(sb.String())
+}
+
+// -----------------------------------------------------------------------------
+
+func (es *EventStream) write(s string) error {
+	es.mtx.Lock()
+	defer es.mtx.Unlock()
+
+	if es.closed {
+		return ErrEventStreamClosed
+	}
+	if es.err != nil {
+		return es.err
+	}
+
+	// The server's write timeout is applied once per response so extend it on each write to keep the stream alive
+	if es.writeTimeout > 0 && es.conn != nil {
+		_ = es.conn.SetWriteDeadline(time.Now().Add(es.writeTimeout))
+	}
+
+	_, err := es.w.WriteString(s)
+	if err == nil {
+		err = es.w.Flush()
+	}
+	if err != nil {
+		es.err = err
+		es.cancelCtx()
+	}
+	return err
+}
+
+func (es *EventStream) close() {
+	es.cancelCtx()
+
+	es.mtx.Lock()
+	es.closed = true
+	es.mtx.Unlock()
+}
+
+func (es *EventStream) watch(serverDone <-chan struct{}, keepAliveInterval time.Duration) {
+	var tickerCh <-chan time.Time
+
+	if keepAliveInterval > 0 {
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		tickerCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-es.ctx.Done():
+			return
+
+		case <-serverDone:
+			es.cancelCtx()
+			return
+
+		case <-tickerCh:
+			_ = es.Comment("ping")
+		}
+	}
+}
+
+func splitEventLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
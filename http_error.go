@@ -0,0 +1,277 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"strconv"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// HTTPError is an error that carries the HTTP status code to send to the client. Handlers can return it and the
+// default request error handler will send it as an RFC 9457 problem details document.
+type HTTPError struct {
+	// Status is the HTTP status code.
+	Status int
+
+	// Type is an optional URI reference that identifies the problem type. Defaults to "about:blank".
+	Type string
+
+	// Title is an optional short summary of the problem type. Defaults to the status text.
+	Title string
+
+	// Detail is an optional explanation, specific to this occurrence of the problem, to send to the client.
+	Detail string
+
+	// Instance is an optional URI reference that identifies the specific occurrence of the problem.
+	Instance string
+
+	// Extensions contains optional additional members to include in the problem details document. Members
+	// with the same name as the standard ones are ignored.
+	Extensions map[string]any
+
+	// Err is an optional underlying error. It is never sent to the client.
+	Err error
+}
+
+type errorStatusMapping struct {
+	target error
+	status int
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	problemTypeDefault = "about:blank"
+)
+
+var (
+	errorStatusMappingsMtx = sync.RWMutex{}
+	errorStatusMappings    = make([]errorStatusMapping, 0)
+
+	errorMediaTypes = []string{
+		MediaTypeProblemJSON,
+		MediaTypeJSON,
+		MediaTypeHTML,
+		MediaTypeTextPlain,
+	}
+)
+
+// -----------------------------------------------------------------------------
+
+// NewHTTPError creates a new HTTPError with the specified status code and detail.
+func NewHTTPError(status int, detail string) *HTTPError {
+	return &HTTPError{
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error returns a description of the error
+func (e *HTTPError) Error() string {
+	msg := e.Detail
+	if len(msg) == 0 {
+		msg = e.title()
+	}
+	if e.Err != nil {
+		msg += " [err=" + e.Err.Error() + "]"
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON encodes the error as an RFC 9457 problem details document.
+func (e *HTTPError) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]any, len(e.Extensions)+5)
+	for k, v := range e.Extensions {
+		doc[k] = v
+	}
+	doc["type"] = e.Type
+	if len(e.Type) == 0 {
+		doc["type"] = problemTypeDefault
+	}
+	doc["title"] = e.title()
+	doc["status"] = e.Status
+	if len(e.Detail) > 0 {
+		doc["detail"] = e.Detail
+	} else {
+		delete(doc, "detail")
+	}
+	if len(e.Instance) > 0 {
+		doc["instance"] = e.Instance
+	} else {
+		delete(doc, "instance")
+	}
+	return json.Marshal(doc)
+}
+
+// withExtension returns a copy of the error with the extension member added, unless it is already present.
+func (e *HTTPError) withExtension(key string, value any) *HTTPError {
+	if _, ok := e.Extensions[key]; ok {
+		return e
+	}
+
+	dup := *e
+	dup.Extensions = make(map[string]any, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		dup.Extensions[k] = v
+	}
+	dup.Extensions[key] = value
+	return &dup
+}
+
+func (e *HTTPError) title() string {
+	if len(e.Title) > 0 {
+		return e.Title
+	}
+	return fasthttp.StatusMessage(e.Status)
+}
+
+// -----------------------------------------------------------------------------
+
+// RegisterErrorStatus maps an error to the HTTP status code the default request error handler will send when a
+// handler returns it, or an error wrapping it. Errors are matched with errors.Is in registration order.
+func RegisterErrorStatus(target error, status int) {
+	if target == nil {
+		panic("invalid error")
+	}
+	if status < 100 || status > 599 {
+		panic("invalid status code")
+	}
+
+	errorStatusMappingsMtx.Lock()
+	defer errorStatusMappingsMtx.Unlock()
+
+	newMappings := make([]errorStatusMapping, 0, len(errorStatusMappings)+1)
+	newMappings = append(newMappings, errorStatusMappings...)
+	newMappings = append(newMappings, errorStatusMapping{
+		target: target,
+		status: status,
+	})
+	errorStatusMappings = newMappings
+}
+
+// ToHTTPError converts an error into an HTTPError. If err is, or wraps, an *HTTPError, it is returned. Binding
+// errors are converted to 400 errors and registered errors to their mapped status code. Other errors are
+// converted to 500 errors.
+func ToHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	var bindErr *BindError
+
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if errors.As(err, &bindErr) {
+		return &HTTPError{
+			Status: fasthttp.StatusBadRequest,
+			Detail: bindErr.Error(),
+			Err:    err,
+		}
+	}
+
+	errorStatusMappingsMtx.RLock()
+	mappings := errorStatusMappings
+	errorStatusMappingsMtx.RUnlock()
+
+	status := fasthttp.StatusInternalServerError
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			status = m.status
+			break
+		}
+	}
+	return &HTTPError{
+		Status: status,
+		Detail: err.Error(),
+		Err:    err,
+	}
+}
+
+// SendError converts the error using ToHTTPError and sends it to the client. The response format depends on the
+// request's Accept header: an RFC 9457 application/problem+json document, which is the default, an HTML page or
+// plain text. If the error wraps a *BindError, the invalid fields are included in the "errors" member of the
+// problem details document.
+func (req *RequestContext) SendError(err error) {
+	var bindErr *BindError
+
+	httpErr := ToHTTPError(err)
+	if errors.As(err, &bindErr) {
+		httpErr = httpErr.withExtension("errors", bindErr.Fields)
+	}
+	if len(req.requestID) > 0 {
+		httpErr = httpErr.withExtension("request_id", req.requestID)
+	}
+
+	// Reset the response
+	req.Error("", httpErr.Status)
+	req.ctx.Response.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderAccept)
+
+	switch negotiateErrorMediaType(req.ctx.Request.Header.Peek(fasthttp.HeaderAccept)) {
+	case MediaTypeHTML:
+		title := html.EscapeString(strconv.Itoa(httpErr.Status) + " " + httpErr.title())
+		body := "<!doctype html>\n<html>\n<head><title>" + title + "</title></head>\n<body>\n<h1>" + title + "</h1>\n"
+		if len(httpErr.Detail) > 0 {
+			body += "<p>" + html.EscapeString(httpErr.Detail) + "</p>\n"
+		}
+		body += "</body>\n</html>\n"
+		req.ctx.Response.Header.SetContentType("text/html; charset=utf-8")
+		req.setErrorBody(body)
+
+	case MediaTypeTextPlain:
+		body := httpErr.Detail
+		if len(body) == 0 {
+			body = httpErr.title()
+		}
+		req.ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
+		req.setErrorBody(body)
+
+	default:
+		encoded, err2 := json.Marshal(httpErr)
+		if err2 != nil {
+			// Extensions cannot be encoded so send the standard members only
+			e := *httpErr
+			e.Extensions = nil
+			encoded, _ = json.Marshal(&e)
+		}
+		req.ctx.Response.Header.SetContentType(MediaTypeProblemJSON)
+		req.setErrorBody(string(encoded))
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (req *RequestContext) setErrorBody(body string) {
+	if !req.IsHead() {
+		req.ctx.SetBodyString(body)
+	}
+}
+
+// negotiateErrorMediaType returns the format to use for an error response. Unlike Render, if the client does not
+// accept any of the supported formats, the problem details document is sent anyway.
+func negotiateErrorMediaType(accept []byte) string {
+	accepted := parseAcceptHeader(string(accept))
+	if len(accepted) == 0 {
+		return MediaTypeProblemJSON
+	}
+
+	best := MediaTypeProblemJSON
+	bestQuality := 0.0
+	for _, mediaType := range errorMediaTypes {
+		quality := mediaTypeQuality(mediaType, accepted)
+		if quality > bestQuality {
+			best = mediaType
+			bestQuality = quality
+		}
+	}
+	return best
+}
@@ -91,6 +91,11 @@ func (req *RequestContext) {{$method.Name}}({{processParams $method.InTypes "in"
 		case "Host":
 		case "RemoteIP":
 
+			// These keep a copy of the response that was sent, see RequestContext.Response
+		case "TimeoutError":
+		case "TimeoutErrorWithCode":
+		case "TimeoutErrorWithResponse":
+
 		default:
 			// Add this method
 			tmplMethod := Method{
@@ -140,6 +140,46 @@ func QueryApiVersion(doPost bool, queryParams map[string]string, headers http.He
 	return resp.StatusCode, resp.Header, nil
 }
 
+func QueryPath(method string, path string, headers http.Header, body io.Reader, expectedStatus []int) (int, http.Header, []byte, error) {
+	req, err := http.NewRequest(method, "http://127.0.0.1:3000"+path, body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if headers != nil {
+		req.Header = headers
+	}
+
+	// Each test starts its own server, so do not keep connections that can outlive it
+	req.Close = true
+
+	reqCtx, reqCtxCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer reqCtxCancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	statusOk := false
+	for _, status := range expectedStatus {
+		if status == resp.StatusCode {
+			statusOk = true
+			break
+		}
+	}
+	if !statusOk {
+		return resp.StatusCode, nil, nil, fmt.Errorf("unexpected status code while querying %v [%v]", path, resp.StatusCode)
+	}
+
+	// Done
+	return resp.StatusCode, resp.Header, respBody, nil
+}
+
 func OpenBrowser(path string) {
 	rawUrl := "http://127.0.0.1:3000" + path
 	switch runtime.GOOS {
@@ -0,0 +1,159 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/mxmauro/go-webserver/v2/util"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// JSONHandlerFunc defines a function that processes a decoded JSON input and returns the output to encode.
+type JSONHandlerFunc[In any, Out any] func(ctx context.Context, req *RequestContext, in In) (Out, error)
+
+// JSONHandlerOptions sets optional parameters for JSONHandler.
+type JSONHandlerOptions struct {
+	// MaxBodySize limits the size of the request body. It cannot be greater than the server's
+	// MaxRequestBodySize setting. Defaults to no limit.
+	MaxBodySize int
+
+	// SuccessStatus is the status code to send when the handler succeeds. Defaults to 200. If set to 204, the
+	// output is not sent.
+	SuccessStatus int
+
+	// RequireBody, if set, rejects requests with an empty body. If not set, an empty body leaves the input with
+	// its zero value except for the fields bound from other sources.
+	RequireBody bool
+}
+
+// -----------------------------------------------------------------------------
+
+// JSONHandler creates a request handler that decodes the JSON body of the request into a value of type In,
+// calls the provided function and encodes the returned value of type Out as JSON.
+//
+// If In is a struct, the input is filled with RequestContext.Bind so path, query, header and form tags, and
+// validation rules are also honored. Requests with a body whose content type is not JSON are rejected with a
+// 415 error, bodies bigger than the limit with 413 and malformed or invalid inputs with 400.
+//
+// If the function returns an error, it is passed to the server's request error handler so returning an
+// *HTTPError sets the response status code.
+func JSONHandler[In any, Out any](fn JSONHandlerFunc[In, Out], opts ...JSONHandlerOptions) HandlerFunc {
+	var o JSONHandlerOptions
+
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.SuccessStatus == 0 {
+		o.SuccessStatus = fasthttp.StatusOK
+	}
+	isStruct := reflect.TypeOf((*In)(nil)).Elem().Kind() == reflect.Struct
+
+	// Create handler
+	return func(req *RequestContext) error {
+		var in In
+
+		// Check body
+		body, err := req.readLimitedBody(o.MaxBodySize)
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			if !isJSONContentType(req.ctx.Request.Header.ContentType()) {
+				return NewHTTPError(fasthttp.StatusUnsupportedMediaType, "")
+			}
+		} else if o.RequireBody {
+			return NewHTTPError(fasthttp.StatusBadRequest, "request body is required")
+		}
+
+		// Decode input
+		if isStruct {
+			err = req.Bind(&in)
+			if err != nil {
+				var bindErr *BindError
+
+				if errors.As(err, &bindErr) {
+					return &HTTPError{
+						Status: fasthttp.StatusBadRequest,
+						Detail: bindErr.Error(),
+						Err:    bindErr,
+					}
+				}
+				return err
+			}
+		} else if len(body) > 0 {
+			err = json.Unmarshal(body, &in)
+			if err != nil {
+				return &HTTPError{
+					Status: fasthttp.StatusBadRequest,
+					Detail: "request body is not a valid JSON document",
+					Err:    err,
+				}
+			}
+		}
+
+		// Call the business logic
+		out, err := fn(req.UserContext(), req, in)
+		if err != nil {
+			return err
+		}
+
+		// Encode output
+		if o.SuccessStatus == fasthttp.StatusNoContent {
+			req.NoContent(fasthttp.StatusNoContent)
+			return nil
+		}
+		encoded, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		req.ctx.Response.Header.SetBytesKV(util.HeaderContentType, util.ContentTypeApplicationJSON)
+		req.ctx.Response.SetStatusCode(o.SuccessStatus)
+		req.ctx.Response.SetBody(encoded)
+
+		// Done
+		return nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// readLimitedBody returns the request body or a 413 error if it is bigger than the given limit. The body is not
+// read if the declared length exceeds the limit and, if the server streams request bodies, it is read up to the
+// limit only.
+func (req *RequestContext) readLimitedBody(maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		return req.ctx.PostBody(), nil
+	}
+	if req.ctx.Request.Header.ContentLength() > maxSize {
+		return nil, NewHTTPError(fasthttp.StatusRequestEntityTooLarge, "")
+	}
+
+	if stream := req.ctx.RequestBodyStream(); stream != nil {
+		body, err := io.ReadAll(io.LimitReader(stream, int64(maxSize)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxSize {
+			return nil, NewHTTPError(fasthttp.StatusRequestEntityTooLarge, "")
+		}
+
+		// Keep the body so it can be read again, for e.g., by Bind
+		req.ctx.Request.SetBody(body)
+		return body, nil
+	}
+
+	body := req.ctx.PostBody()
+	if len(body) > maxSize {
+		return nil, NewHTTPError(fasthttp.StatusRequestEntityTooLarge, "")
+	}
+
+	// Done
+	return body, nil
+}
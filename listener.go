@@ -0,0 +1,217 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mxmauro/go-webserver/v2/proxy_protocol"
+)
+
+// -----------------------------------------------------------------------------
+
+// ListenEndpoint specifies an additional address where the server will accept connections.
+type ListenEndpoint struct {
+	// Address is the endpoint to listen on. Use "ip:port" for TCP sockets, "unix:/path/to/file.sock" for Unix
+	// domain sockets and "unix:@name" for abstract sockets (only available on Linux).
+	Address string
+
+	// FileMode, if not zero, changes the access permissions of the Unix domain socket file.
+	FileMode os.FileMode
+
+	// UID and GID, if not nil, change the ownership of the Unix domain socket file.
+	UID *int
+	GID *int
+}
+
+type listenEndpoint struct {
+	network  string
+	address  string
+	fileMode os.FileMode
+	uid      int
+	gid      int
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	unixEndpointPrefix = "unix:"
+)
+
+// -----------------------------------------------------------------------------
+
+func newTcpListenEndpoint(bindAddress net.IP, port uint16) listenEndpoint {
+	// "tcp" network is not supported by all platforms
+	if p4 := bindAddress.To4(); len(p4) == net.IPv4len {
+		return listenEndpoint{
+			network: "tcp4",
+			address: p4.String() + ":" + strconv.Itoa(int(port)),
+			uid:     -1,
+			gid:     -1,
+		}
+	}
+	return listenEndpoint{
+		network: "tcp6",
+		address: "[" + bindAddress.String() + "]:" + strconv.Itoa(int(port)),
+		uid:     -1,
+		gid:     -1,
+	}
+}
+
+func parseListenEndpoint(ep ListenEndpoint) (listenEndpoint, error) {
+	if strings.HasPrefix(ep.Address, unixEndpointPrefix) {
+		path := ep.Address[len(unixEndpointPrefix):]
+		if len(path) == 0 {
+			return listenEndpoint{}, errors.New("invalid unix socket path")
+		}
+
+		result := listenEndpoint{
+			network:  "unix",
+			address:  path,
+			fileMode: ep.FileMode,
+			uid:      -1,
+			gid:      -1,
+		}
+		if path[0] == '@' {
+			if runtime.GOOS != "linux" {
+				return listenEndpoint{}, errors.New("abstract unix sockets are only supported on Linux")
+			}
+			if ep.FileMode != 0 || ep.UID != nil || ep.GID != nil {
+				return listenEndpoint{}, errors.New("abstract unix sockets do not support file mode or ownership")
+			}
+		} else {
+			if ep.UID != nil {
+				result.uid = *ep.UID
+			}
+			if ep.GID != nil {
+				result.gid = *ep.GID
+			}
+		}
+		return result, nil
+	}
+
+	if ep.FileMode != 0 || ep.UID != nil || ep.GID != nil {
+		return listenEndpoint{}, errors.New("file mode and ownership are only valid on unix sockets")
+	}
+
+	host, port, err := net.SplitHostPort(ep.Address)
+	if err != nil {
+		return listenEndpoint{}, errors.New("invalid listen endpoint address")
+	}
+	parsedBindAddress := net.ParseIP(host)
+	if parsedBindAddress == nil {
+		return listenEndpoint{}, errors.New("invalid listen endpoint address")
+	}
+	parsedPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || parsedPort < 1 {
+		return listenEndpoint{}, errors.New("invalid listen endpoint port")
+	}
+
+	// Done
+	return newTcpListenEndpoint(parsedBindAddress, uint16(parsedPort)), nil
+}
+
+func (ep *listenEndpoint) String() string {
+	if ep.network == "unix" {
+		return unixEndpointPrefix + ep.address
+	}
+	return ep.address
+}
+
+func (ep *listenEndpoint) listen() (net.Listener, error) {
+	if ep.network != "unix" {
+		return createListener(ep.network, ep.address)
+	}
+
+	isAbstract := ep.address[0] == '@'
+
+	// Remove stale socket files left by a previous instance
+	if !isAbstract {
+		fi, err := os.Lstat(ep.address)
+		if err == nil {
+			if fi.Mode()&os.ModeSocket == 0 {
+				return nil, errors.New("unix socket path already exists and it is not a socket")
+			}
+
+			// Only remove the socket if nobody is listening on it
+			var conn net.Conn
+
+			conn, err = net.Dial("unix", ep.address)
+			if err == nil {
+				_ = conn.Close()
+				return nil, errors.New("address already in use")
+			}
+			if !errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, err
+			}
+			err = os.Remove(ep.address)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ln, err := net.Listen("unix", ep.address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set permissions and ownership
+	if !isAbstract {
+		if ep.fileMode != 0 {
+			err = os.Chmod(ep.address, ep.fileMode)
+		}
+		if err == nil && (ep.uid >= 0 || ep.gid >= 0) {
+			err = os.Chown(ep.address, ep.uid, ep.gid)
+		}
+		if err != nil {
+			_ = ln.Close()
+			return nil, err
+		}
+	}
+
+	// Done
+	return ln, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (srv *Server) wrapListeners(lns []net.Listener) []net.Listener {
+	wrapped := make([]net.Listener, len(lns))
+	for idx, ln := range lns {
+		// Wrap listener into a PROXY protocol parser if enabled. It must be done before TLS because the header
+		// is sent unencrypted.
+		if srv.proxyProtocol != nil {
+			ln = proxy_protocol.NewListener(ln, *srv.proxyProtocol)
+		}
+
+		// Wrap listener into a TLS listener if a TLS configuration was specified
+		if srv.fastserver.TLSConfig != nil {
+			ln = tls.NewListener(ln, srv.fastserver.TLSConfig.Clone())
+		}
+
+		wrapped[idx] = ln
+	}
+	return wrapped
+}
+
+func listenerName(ln net.Listener) string {
+	addr := ln.Addr()
+	if addr.Network() == "unix" {
+		return unixEndpointPrefix + addr.String()
+	}
+	return addr.String()
+}
+
+func closeListeners(lns []net.Listener) {
+	for _, ln := range lns {
+		_ = ln.Close()
+	}
+}
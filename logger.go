@@ -0,0 +1,83 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// loggerBridge sends the messages of the fasthttp server to the server's logger.
+type loggerBridge struct {
+	logger *slog.Logger
+}
+
+// discardHandler is a slog handler that drops all the records.
+type discardHandler struct{}
+
+// -----------------------------------------------------------------------------
+
+const (
+	fasthttpConnErrorFormat = "error when serving connection %q<->%q: %v"
+)
+
+// -----------------------------------------------------------------------------
+
+func newLoggerBridge(logger *slog.Logger) *loggerBridge {
+	return &loggerBridge{
+		logger: logger,
+	}
+}
+
+func (l *loggerBridge) Printf(format string, args ...interface{}) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("component", "fasthttp"),
+	}
+
+	switch {
+	case format == fasthttpConnErrorFormat && len(args) == 3:
+		// Connection resets, malformed requests, TLS handshake failures, etc.
+		level = slog.LevelWarn
+		attrs = append(attrs,
+			slog.Any("local_addr", args[0]),
+			slog.Any("remote_addr", args[1]),
+			slog.Any("error", args[2]),
+		)
+
+	case strings.HasPrefix(format, "Permanent error"):
+		level = slog.LevelError
+
+	case strings.Contains(format, "error") || strings.Contains(format, "cannot be served") ||
+		strings.Contains(format, "exceeds"):
+		level = slog.LevelWarn
+	}
+
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.LogAttrs(ctx, level, fmt.Sprintf(format, args...), attrs...)
+}
+
+// -----------------------------------------------------------------------------
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool {
+	return false
+}
+
+func (discardHandler) Handle(context.Context, slog.Record) error {
+	return nil
+}
+
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h discardHandler) WithGroup(string) slog.Handler {
+	return h
+}
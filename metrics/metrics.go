@@ -43,6 +43,9 @@ type Options struct {
 	// Port is the port number the internal web server will use.
 	Port uint16
 
+	// ListenEndpoints is an optional list of extra endpoints where the internal web server will accept connections.
+	ListenEndpoints []webserver.ListenEndpoint
+
 	// TLSConfig optionally provides a TLS configuration for use.
 	TLSConfig *tls.Config
 
@@ -112,6 +115,7 @@ func CreateController(opts Options) (*Controller, error) {
 			Name:               serverName,
 			Address:            opts.Address,
 			Port:               opts.Port,
+			ListenEndpoints:    opts.ListenEndpoints,
 			ReadTimeout:        10 * time.Second, // 10 seconds for reading a metrics request
 			WriteTimeout:       time.Minute,      // and 1 minute for write
 			MaxRequestBodySize: 512,              // Currently, no POST endpoints but leave a small buffer for future requests.
@@ -236,6 +240,12 @@ func (mws *Controller) Stop() {
 	mws.healthCallback = nil
 }
 
+// Server returns the web server used by the controller. If the controller owns it, it can be mounted into
+// another server instead of being started, for e.g.: srv.Mount("/_ops", controller.Server())
+func (mws *Controller) Server() *webserver.Server {
+	return mws.server
+}
+
 // Registry returns the prometheus registry object
 func (mws *Controller) Registry() *prometheus.Registry {
 	return mws.registry
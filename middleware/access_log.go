@@ -0,0 +1,430 @@
+// See the LICENSE file for license details.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// AccessLogFormat specifies the format of the lines written by the access log middleware.
+type AccessLogFormat int
+
+const (
+	// AccessLogCombined is the Apache/NGINX combined log format. It is the default.
+	AccessLogCombined AccessLogFormat = iota
+
+	// AccessLogCommon is the Apache common log format.
+	AccessLogCommon
+
+	// AccessLogJSON writes one JSON object per line.
+	AccessLogJSON
+
+	// AccessLogLogfmt writes one line of key=value pairs.
+	AccessLogLogfmt
+)
+
+// AccessLogOptions defines the behavior of the access log middleware.
+type AccessLogOptions struct {
+	// Format is the format of the lines written to Output. Ignored if Template or Handler are set.
+	Format AccessLogFormat
+
+	// Template, if set, is a text/template used to format the lines written to Output. The template is executed
+	// with an *AccessLogEntry, for e.g., `{{.Method}} {{.URI}} {{.Status}} {{.Latency}}`.
+	Template string
+
+	// Output is where lines are written. Defaults to os.Stdout. Use a RotatingFile to write them to disk.
+	Output io.Writer
+
+	// Handler, if set, receives the entries as structured records instead of writing lines to Output. Responses
+	// with 4xx status codes are logged with the warning level and 5xx ones with the error level.
+	Handler slog.Handler
+
+	// Headers is a list of request headers to include in the entries.
+	Headers []string
+
+	// RedactHeaders is a list of headers whose values are replaced by "[REDACTED]". Defaults to Authorization,
+	// Proxy-Authorization, Cookie and X-Api-Key.
+	RedactHeaders []string
+
+	// RedactQuery is a list of query arguments whose values are replaced by "[REDACTED]" in the logged URI.
+	RedactQuery []string
+
+	// RequestIDHeader is the header to take the request id from if none was assigned with
+	// RequestContext.SetRequestID, for e.g., by the request id middleware. It is looked up in the response first.
+	// Defaults to X-Request-ID.
+	RequestIDHeader string
+
+	// SampleRate is the fraction, between 0 and 1, of requests to log. Responses with a status of 500 or higher
+	// are always logged. Defaults to 1.
+	SampleRate float64
+
+	// Skip, if set, is called to check if a request must not be logged, for e.g., health checks.
+	Skip func(req *webserver.RequestContext) bool
+}
+
+// AccessLogEntry contains the details of a request logged by the access log middleware.
+type AccessLogEntry struct {
+	Time      time.Time
+	RemoteIP  string
+	Host      string
+	Method    string
+	URI       string
+	Protocol  string
+	Route     string
+	Status    int
+	BytesIn   int64
+	BytesOut  int64
+	Latency   time.Duration
+	RequestID string
+	UserAgent string
+	Referer   string
+	Headers   map[string]string
+}
+
+type accessLogger struct {
+	mtx           sync.Mutex
+	opts          AccessLogOptions
+	tmpl          *template.Template
+	redactHeaders map[string]struct{}
+	redactQuery   map[string]struct{}
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	accessLogRedacted = "[REDACTED]"
+
+	defaultRequestIDHeader = "X-Request-ID"
+
+	commonLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+var defaultRedactHeaders = []string{
+	fasthttp.HeaderAuthorization,
+	fasthttp.HeaderProxyAuthorization,
+	fasthttp.HeaderCookie,
+	"X-Api-Key",
+}
+
+// -----------------------------------------------------------------------------
+
+// NewAccessLog creates a middleware that logs the requests once they are processed. Add it before other
+// middlewares so the latency and the final response, including the one sent for errors returned by the handler
+// chain, are logged.
+func NewAccessLog(opts AccessLogOptions) webserver.HandlerFunc {
+	l := accessLogger{
+		opts:          opts,
+		redactHeaders: make(map[string]struct{}),
+		redactQuery:   make(map[string]struct{}),
+	}
+
+	if len(opts.Template) > 0 {
+		l.tmpl = template.Must(template.New("access-log").Parse(opts.Template))
+	}
+	if l.opts.Output == nil {
+		l.opts.Output = os.Stdout
+	}
+	if len(l.opts.RequestIDHeader) == 0 {
+		l.opts.RequestIDHeader = defaultRequestIDHeader
+	}
+	if l.opts.SampleRate <= 0 || l.opts.SampleRate > 1 {
+		l.opts.SampleRate = 1
+	}
+	if opts.RedactHeaders == nil {
+		opts.RedactHeaders = defaultRedactHeaders
+	}
+	for _, h := range opts.RedactHeaders {
+		l.redactHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	for _, q := range opts.RedactQuery {
+		l.redactQuery[q] = struct{}{}
+	}
+
+	// Setup middleware function
+	return func(req *webserver.RequestContext) error {
+		if l.opts.Skip != nil && l.opts.Skip(req) {
+			return req.Next()
+		}
+
+		start := time.Now()
+
+		// Send errors here so the final response is logged
+		err := req.Next()
+		if err != nil {
+			req.HandleError(err)
+		}
+
+		status := req.Response().StatusCode()
+		if status < 500 && l.opts.SampleRate < 1 && rand.Float64() >= l.opts.SampleRate {
+			return nil
+		}
+
+		entry := l.newEntry(req, start)
+		l.log(&entry)
+
+		// Done
+		return nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (l *accessLogger) newEntry(req *webserver.RequestContext, start time.Time) AccessLogEntry {
+	request := req.Request()
+	response := req.Response()
+
+	entry := AccessLogEntry{
+		Time:      start,
+		RemoteIP:  req.RemoteIP().String(),
+		Host:      req.Host(),
+		Method:    string(request.Header.Method()),
+		URI:       l.redactURI(request.URI()),
+		Protocol:  string(request.Header.Protocol()),
+		Route:     req.RoutePattern(),
+		Status:    response.StatusCode(),
+		Latency:   time.Since(start),
+		UserAgent: string(request.Header.UserAgent()),
+		Referer:   string(request.Header.Referer()),
+	}
+
+	if contentLength := request.Header.ContentLength(); contentLength > 0 {
+		entry.BytesIn = int64(contentLength)
+	}
+	if response.IsBodyStream() {
+		// Avoid reading the stream
+		if contentLength := response.Header.ContentLength(); contentLength > 0 {
+			entry.BytesOut = int64(contentLength)
+		}
+	} else {
+		entry.BytesOut = int64(len(response.Body()))
+	}
+
+	entry.RequestID = req.RequestID()
+	if len(entry.RequestID) == 0 {
+		requestID := response.Header.Peek(l.opts.RequestIDHeader)
+		if len(requestID) == 0 {
+			requestID = request.Header.Peek(l.opts.RequestIDHeader)
+		}
+		entry.RequestID = string(requestID)
+	}
+
+	if len(l.opts.Headers) > 0 {
+		entry.Headers = make(map[string]string, len(l.opts.Headers))
+		for _, h := range l.opts.Headers {
+			value := request.Header.Peek(h)
+			if len(value) > 0 {
+				h = http.CanonicalHeaderKey(h)
+				if _, redact := l.redactHeaders[h]; redact {
+					entry.Headers[h] = accessLogRedacted
+				} else {
+					entry.Headers[h] = string(value)
+				}
+			}
+		}
+	}
+
+	// Done
+	return entry
+}
+
+func (l *accessLogger) redactURI(uri *fasthttp.URI) string {
+	query := string(uri.QueryString())
+	if len(l.redactQuery) == 0 || len(query) == 0 {
+		return string(uri.RequestURI())
+	}
+
+	// Keep the original encoding of the arguments that are not redacted
+	args := strings.Split(query, "&")
+	for idx, arg := range args {
+		key, _, _ := strings.Cut(arg, "=")
+		if unescapedKey, err := url.QueryUnescape(key); err == nil {
+			if _, redact := l.redactQuery[unescapedKey]; redact {
+				args[idx] = key + "=" + accessLogRedacted
+			}
+		}
+	}
+	return string(uri.PathOriginal()) + "?" + strings.Join(args, "&")
+}
+
+func (l *accessLogger) log(entry *AccessLogEntry) {
+	if l.opts.Handler != nil {
+		l.logRecord(entry)
+		return
+	}
+
+	buf := bytes.Buffer{}
+	switch {
+	case l.tmpl != nil:
+		if l.tmpl.Execute(&buf, entry) != nil {
+			return
+		}
+	case l.opts.Format == AccessLogCommon:
+		writeCommonLog(&buf, entry, false)
+	case l.opts.Format == AccessLogJSON:
+		writeJSONLog(&buf, entry)
+	case l.opts.Format == AccessLogLogfmt:
+		writeLogfmtLog(&buf, entry)
+	default:
+		writeCommonLog(&buf, entry, true)
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		_ = buf.WriteByte('\n')
+	}
+
+	l.mtx.Lock()
+	_, _ = l.opts.Output.Write(buf.Bytes())
+	l.mtx.Unlock()
+}
+
+func (l *accessLogger) logRecord(entry *AccessLogEntry) {
+	level := slog.LevelInfo
+	if entry.Status >= 500 {
+		level = slog.LevelError
+	} else if entry.Status >= 400 {
+		level = slog.LevelWarn
+	}
+
+	ctx := context.Background()
+	if !l.opts.Handler.Enabled(ctx, level) {
+		return
+	}
+
+	record := slog.NewRecord(entry.Time, level, "request", 0)
+	record.AddAttrs(
+		slog.String("remote_ip", entry.RemoteIP),
+		slog.String("host", entry.Host),
+		slog.String("method", entry.Method),
+		slog.String("uri", entry.URI),
+		slog.String("protocol", entry.Protocol),
+		slog.String("route", entry.Route),
+		slog.Int("status", entry.Status),
+		slog.Int64("bytes_in", entry.BytesIn),
+		slog.Int64("bytes_out", entry.BytesOut),
+		slog.Duration("latency", entry.Latency),
+		slog.String("request_id", entry.RequestID),
+		slog.String("user_agent", entry.UserAgent),
+		slog.String("referer", entry.Referer),
+	)
+	if len(entry.Headers) > 0 {
+		attrs := make([]any, 0, len(entry.Headers))
+		for k, v := range entry.Headers {
+			attrs = append(attrs, slog.String(k, v))
+		}
+		record.AddAttrs(slog.Group("headers", attrs...))
+	}
+	_ = l.opts.Handler.Handle(ctx, record)
+}
+
+func writeCommonLog(buf *bytes.Buffer, entry *AccessLogEntry, combined bool) {
+	_, _ = buf.WriteString(entry.RemoteIP)
+	_, _ = buf.WriteString(" - - [")
+	_, _ = buf.WriteString(entry.Time.Format(commonLogTimeFormat))
+	_, _ = buf.WriteString("] \"")
+	_, _ = buf.WriteString(entry.Method + " " + entry.URI + " " + entry.Protocol)
+	_, _ = buf.WriteString("\" ")
+	_, _ = buf.WriteString(strconv.Itoa(entry.Status))
+	_ = buf.WriteByte(' ')
+	if entry.BytesOut > 0 {
+		_, _ = buf.WriteString(strconv.FormatInt(entry.BytesOut, 10))
+	} else {
+		_ = buf.WriteByte('-')
+	}
+	if combined {
+		_, _ = buf.WriteString(" " + strconv.Quote(entry.Referer) + " " + strconv.Quote(entry.UserAgent))
+	}
+}
+
+func writeJSONLog(buf *bytes.Buffer, entry *AccessLogEntry) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(struct {
+		Time      string            `json:"time"`
+		RemoteIP  string            `json:"remote_ip"`
+		Host      string            `json:"host"`
+		Method    string            `json:"method"`
+		URI       string            `json:"uri"`
+		Protocol  string            `json:"protocol"`
+		Route     string            `json:"route,omitempty"`
+		Status    int               `json:"status"`
+		BytesIn   int64             `json:"bytes_in"`
+		BytesOut  int64             `json:"bytes_out"`
+		LatencyMs float64           `json:"latency_ms"`
+		RequestID string            `json:"request_id,omitempty"`
+		UserAgent string            `json:"user_agent,omitempty"`
+		Referer   string            `json:"referer,omitempty"`
+		Headers   map[string]string `json:"headers,omitempty"`
+	}{
+		Time:      entry.Time.Format(time.RFC3339Nano),
+		RemoteIP:  entry.RemoteIP,
+		Host:      entry.Host,
+		Method:    entry.Method,
+		URI:       entry.URI,
+		Protocol:  entry.Protocol,
+		Route:     entry.Route,
+		Status:    entry.Status,
+		BytesIn:   entry.BytesIn,
+		BytesOut:  entry.BytesOut,
+		LatencyMs: float64(entry.Latency.Microseconds()) / 1000,
+		RequestID: entry.RequestID,
+		UserAgent: entry.UserAgent,
+		Referer:   entry.Referer,
+		Headers:   entry.Headers,
+	})
+}
+
+func writeLogfmtLog(buf *bytes.Buffer, entry *AccessLogEntry) {
+	writeLogfmtPair(buf, "time", entry.Time.Format(time.RFC3339Nano))
+	writeLogfmtPair(buf, "remote_ip", entry.RemoteIP)
+	writeLogfmtPair(buf, "host", entry.Host)
+	writeLogfmtPair(buf, "method", entry.Method)
+	writeLogfmtPair(buf, "uri", entry.URI)
+	writeLogfmtPair(buf, "protocol", entry.Protocol)
+	writeLogfmtPair(buf, "route", entry.Route)
+	writeLogfmtPair(buf, "status", strconv.Itoa(entry.Status))
+	writeLogfmtPair(buf, "bytes_in", strconv.FormatInt(entry.BytesIn, 10))
+	writeLogfmtPair(buf, "bytes_out", strconv.FormatInt(entry.BytesOut, 10))
+	writeLogfmtPair(buf, "latency", entry.Latency.String())
+	writeLogfmtPair(buf, "request_id", entry.RequestID)
+	writeLogfmtPair(buf, "user_agent", entry.UserAgent)
+	writeLogfmtPair(buf, "referer", entry.Referer)
+	keys := make([]string, 0, len(entry.Headers))
+	for k := range entry.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeLogfmtPair(buf, "header."+strings.ToLower(k), entry.Headers[k])
+	}
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key string, value string) {
+	if buf.Len() > 0 {
+		_ = buf.WriteByte(' ')
+	}
+	_, _ = buf.WriteString(key)
+	_ = buf.WriteByte('=')
+	if len(value) == 0 || strings.ContainsAny(value, " =\"\\\t\r\n") {
+		_, _ = buf.WriteString(strconv.Quote(value))
+	} else {
+		_, _ = buf.WriteString(value)
+	}
+}
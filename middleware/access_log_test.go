@@ -0,0 +1,195 @@
+// See the LICENSE file for license details.
+
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+	"github.com/mxmauro/go-webserver/v2/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+// -----------------------------------------------------------------------------
+
+func TestMiddlewareAccessLog(t *testing.T) {
+	jsonOutput := &syncBuffer{}
+	combinedOutput := &syncBuffer{}
+	slogOutput := &syncBuffer{}
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(middleware.NewAccessLog(middleware.AccessLogOptions{
+			Format:      middleware.AccessLogJSON,
+			Output:      jsonOutput,
+			Headers:     []string{"Authorization", "X-Tenant"},
+			RedactQuery: []string{"token"},
+		}))
+		srv.Use(middleware.NewAccessLog(middleware.AccessLogOptions{
+			Output: combinedOutput,
+			Skip: func(req *webserver.RequestContext) bool {
+				return string(req.Path()) == "/api/version"
+			},
+		}))
+		srv.Use(middleware.NewAccessLog(middleware.AccessLogOptions{
+			Handler: slog.NewJSONHandler(slogOutput, nil),
+		}))
+
+		srv.GET("/items/{id}", func(req *webserver.RequestContext) error {
+			_, _ = req.WriteString("item")
+			return nil
+		})
+		srv.POST("/items", func(req *webserver.RequestContext) error {
+			return webserver.NewHTTPError(http.StatusConflict, "item already exists")
+		})
+		return nil
+	})
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	headers.Set("X-Tenant", "acme")
+	headers.Set("X-Request-ID", "req-1")
+	headers.Set("User-Agent", "tester")
+	_, _, _, err := testcommon.QueryPath(http.MethodGet, "/items/1?token=abc&page=2", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/items", nil, strings.NewReader("{}"), []int{409})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/api/version", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// JSON format
+	lines := jsonOutput.lines()
+	if len(lines) != 3 {
+		t.Fatalf("unexpected number of json entries [%d]", len(lines))
+	}
+	var entry map[string]any
+	err = json.Unmarshal([]byte(lines[0]), &entry)
+	if err != nil {
+		t.Fatalf("unable to decode entry [%v]", err)
+	}
+	if entry["uri"] != "/items/1?token=[REDACTED]&page=2" || entry["route"] != "/items/{id}" ||
+		entry["status"] != float64(200) || entry["bytes_out"] != float64(4) || entry["request_id"] != "req-1" ||
+		entry["user_agent"] != "tester" || entry["remote_ip"] != "127.0.0.1" {
+		t.Fatalf("unexpected entry [%v]", lines[0])
+	}
+	entryHeaders, _ := entry["headers"].(map[string]any)
+	if entryHeaders["Authorization"] != "[REDACTED]" || entryHeaders["X-Tenant"] != "acme" {
+		t.Fatalf("unexpected headers [%v]", lines[0])
+	}
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	if err != nil || entry["status"] != float64(409) || entry["bytes_in"] != float64(2) {
+		t.Fatalf("unexpected entry [%v]", lines[1])
+	}
+
+	// Combined format
+	lines = combinedOutput.lines()
+	if len(lines) != 2 {
+		t.Fatalf("unexpected number of combined entries [%d]", len(lines))
+	}
+	re := regexp.MustCompile(`^127\.0\.0\.1 - - \[[^]]+] "GET /items/1\?token=abc&page=2 HTTP/1\.1" 200 4 "" "tester"$`)
+	if !re.MatchString(lines[0]) {
+		t.Fatalf("unexpected combined entry [%v]", lines[0])
+	}
+
+	// Structured records
+	lines = slogOutput.lines()
+	if len(lines) != 3 {
+		t.Fatalf("unexpected number of records [%d]", len(lines))
+	}
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	if err != nil || entry["level"] != "WARN" || entry["status"] != float64(409) || entry["route"] != "/items" {
+		t.Fatalf("unexpected record [%v]", lines[1])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	rf, err := middleware.NewRotatingFile(middleware.RotatingFileOptions{
+		Filename:   filepath.Join(dir, "access.log"),
+		MaxSize:    100,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	if err != nil {
+		t.Fatalf("unable to create rotating file [%v]", err)
+	}
+
+	line := []byte(strings.Repeat("x", 59) + "\n")
+	for idx := 0; idx < 5; idx++ {
+		_, err = rf.Write(line)
+		if err != nil {
+			t.Fatalf("unable to write [%v]", err)
+		}
+		err = rf.Rotate()
+		if err != nil {
+			t.Fatalf("unable to rotate [%v]", err)
+		}
+	}
+	_, _ = rf.Write(line)
+	_, _ = rf.Write(line) // Rotated because of size
+	err = rf.Close()
+	if err != nil {
+		t.Fatalf("unable to close [%v]", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read directory [%v]", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 {
+		t.Fatalf("unexpected files %v", names)
+	}
+	for _, name := range names {
+		if name != "access.log" && !strings.HasSuffix(name, ".log.gz") {
+			t.Fatalf("unexpected files %v", names)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "access.log"))
+	if err != nil || info.Size() != int64(len(line)) {
+		t.Fatalf("unexpected current file size")
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
@@ -20,6 +20,13 @@ var (
 
 // -----------------------------------------------------------------------------
 
+func init() {
+	webserver.RegisterErrorStatus(ErrNoKeyProvided, http.StatusUnauthorized)
+	webserver.RegisterErrorStatus(ErrNotAuthorized, http.StatusUnauthorized)
+}
+
+// -----------------------------------------------------------------------------
+
 // AuthErrorHandler defines a function to call when the authorization fails
 type AuthErrorHandler func(req *webserver.RequestContext, err error) error
 
@@ -0,0 +1,216 @@
+// See the LICENSE file for license details.
+
+package middleware
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+)
+
+// -----------------------------------------------------------------------------
+
+var (
+	ErrNoClientCertificate      = errors.New("no client certificate provided")
+	ErrInvalidClientCertificate = errors.New("invalid client certificate")
+)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	webserver.RegisterErrorStatus(ErrNoClientCertificate, http.StatusUnauthorized)
+	webserver.RegisterErrorStatus(ErrInvalidClientCertificate, http.StatusUnauthorized)
+}
+
+// -----------------------------------------------------------------------------
+
+// ClientCertValidatorFunc defines a function that verifies if the given client identity is authorized
+type ClientCertValidatorFunc func(req *webserver.RequestContext, identity *ClientCertIdentity) (bool, error)
+
+// ClientCertMapperFunc defines a function that derives the caller's own identity, like a user or a service
+// account, from the verified client identity. Return nil to reject the request.
+type ClientCertMapperFunc func(req *webserver.RequestContext, identity *ClientCertIdentity) (any, error)
+
+// ClientCertAuthOptions defines a mutual TLS client certificate authorization check
+type ClientCertAuthOptions struct {
+	// ErrorHandler defines a handler to execute if authorization fails. If not defined, will return 401.
+	ErrorHandler AuthErrorHandler
+
+	// ClientCAs is the set of root certificate authorities used to verify client certificates. If not defined,
+	// the chains verified during the TLS handshake are used, so the server's TLS configuration must have ClientAuth
+	// set to tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert.
+	ClientCAs *x509.CertPool
+
+	// KeyUsages specifies which extended key usage values are acceptable. Defaults to x509.ExtKeyUsageClientAuth.
+	KeyUsages []x509.ExtKeyUsage
+
+	// ValidateHandler is an optional function to authorize the verified identity.
+	ValidateHandler ClientCertValidatorFunc
+
+	// MapHandler is an optional function to derive the caller's own identity from the verified one. It runs after
+	// ValidateHandler and the returned value is stored in ClientCertIdentity.Principal.
+	MapHandler ClientCertMapperFunc
+}
+
+// ClientCertIdentity contains the identity extracted from a verified client certificate.
+type ClientCertIdentity struct {
+	// Certificate is the client's leaf certificate.
+	Certificate *x509.Certificate
+
+	// Chain is the verified certificate chain, starting with the leaf and ending with the root authority.
+	Chain []*x509.Certificate
+
+	// Subject is the certificate subject.
+	Subject pkix.Name
+
+	// DNSNames, EmailAddresses and URIs are the subject alternative names.
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+
+	// SPIFFEID is the first "spiffe://" URI subject alternative name, if any.
+	SPIFFEID string
+
+	// Principal is the identity returned by ClientCertAuthOptions.MapHandler, if defined.
+	Principal any
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	clientCertIdentityKey = "\xFF\xFF**clientCertIdentityKey"
+)
+
+// -----------------------------------------------------------------------------
+
+// NewClientCertAuth wraps a middleware that verifies the client certificate presented in a mutual TLS connection
+func NewClientCertAuth(opts ClientCertAuthOptions) webserver.HandlerFunc {
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = func(req *webserver.RequestContext, err error) error {
+			req.Unauthorized(err.Error())
+			return nil
+		}
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	// Setup middleware function
+	return func(req *webserver.RequestContext) error {
+		var chain []*x509.Certificate
+
+		// Get the peer certificates
+		state := req.TLSConnectionState()
+		if state == nil || len(state.PeerCertificates) == 0 {
+			return opts.ErrorHandler(req, ErrNoClientCertificate)
+		}
+
+		// Verify the chain
+		if opts.ClientCAs != nil {
+			intermediates := x509.NewCertPool()
+			for _, crt := range state.PeerCertificates[1:] {
+				intermediates.AddCert(crt)
+			}
+
+			chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         opts.ClientCAs,
+				Intermediates: intermediates,
+				CurrentTime:   time.Now(),
+				KeyUsages:     opts.KeyUsages,
+			})
+			if err != nil || len(chains) == 0 {
+				return opts.ErrorHandler(req, ErrInvalidClientCertificate)
+			}
+			chain = chains[0]
+		} else {
+			if len(state.VerifiedChains) == 0 {
+				return opts.ErrorHandler(req, ErrInvalidClientCertificate)
+			}
+			chain = state.VerifiedChains[0]
+
+			// The handshake verifies the chain against the usages of the TLS configuration, so check ours too
+			if !hasExtKeyUsage(chain[0], opts.KeyUsages) {
+				return opts.ErrorHandler(req, ErrInvalidClientCertificate)
+			}
+		}
+
+		// Build the identity
+		leaf := chain[0]
+		identity := &ClientCertIdentity{
+			Certificate:    leaf,
+			Chain:          chain,
+			Subject:        leaf.Subject,
+			DNSNames:       leaf.DNSNames,
+			EmailAddresses: leaf.EmailAddresses,
+			URIs:           leaf.URIs,
+		}
+		for _, uri := range leaf.URIs {
+			if uri.Scheme == "spiffe" {
+				identity.SPIFFEID = uri.String()
+				break
+			}
+		}
+
+		// Authorize it
+		if opts.ValidateHandler != nil {
+			success, err := opts.ValidateHandler(req, identity)
+			if err != nil {
+				return err
+			}
+			if !success {
+				return opts.ErrorHandler(req, ErrNotAuthorized)
+			}
+		}
+
+		// Derive the caller's own identity
+		if opts.MapHandler != nil {
+			principal, err := opts.MapHandler(req, identity)
+			if err != nil {
+				return err
+			}
+			if principal == nil {
+				return opts.ErrorHandler(req, ErrNotAuthorized)
+			}
+			identity.Principal = principal
+		}
+
+		// Make the identity available to the next handlers
+		req.SetUserValue(clientCertIdentityKey, identity)
+
+		// Run next middleware
+		return req.Next()
+	}
+}
+
+// GetClientCertIdentity returns the client identity stored by the client certificate authorization middleware
+// or nil if not available.
+func GetClientCertIdentity(req *webserver.RequestContext) *ClientCertIdentity {
+	identity, _ := req.UserValue(clientCertIdentityKey).(*ClientCertIdentity)
+	return identity
+}
+
+// -----------------------------------------------------------------------------
+
+// hasExtKeyUsage checks if the certificate is valid for any of the given usages, following the same rules of
+// x509.Certificate.Verify for the leaf.
+func hasExtKeyUsage(crt *x509.Certificate, usages []x509.ExtKeyUsage) bool {
+	if len(crt.ExtKeyUsage) == 0 && len(crt.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range usages {
+		if usage == x509.ExtKeyUsageAny {
+			return true
+		}
+		for _, crtUsage := range crt.ExtKeyUsage {
+			if crtUsage == usage || crtUsage == x509.ExtKeyUsageAny {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+// See the LICENSE file for license details.
+
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// -----------------------------------------------------------------------------
+
+func TestMiddlewareClientCertAuth(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+	untrustedCA := newTestCA(t, "untrusted-ca")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{
+				serverCA.issue(t, &x509.Certificate{
+					Subject:     pkix.Name{CommonName: "127.0.0.1"},
+					IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+					ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+				}),
+			},
+			ClientAuth: tls.RequestClientCert,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/whoami", func(req *webserver.RequestContext) error {
+		identity := middleware.GetClientCertIdentity(req)
+		_, _ = req.WriteString(identity.Principal.(string) + "|" + identity.Subject.CommonName + "|" +
+			strings.Join(identity.DNSNames, ","))
+		return nil
+	}, middleware.NewClientCertAuth(middleware.ClientCertAuthOptions{
+		ClientCAs: clientCAs,
+		ValidateHandler: func(_ *webserver.RequestContext, identity *middleware.ClientCertIdentity) (bool, error) {
+			// Only workloads of the production namespace are allowed
+			if len(identity.SPIFFEID) > 0 && !strings.HasPrefix(identity.SPIFFEID, "spiffe://example.test/ns/prod/") {
+				return false, nil
+			}
+			return true, nil
+		},
+		MapHandler: func(_ *webserver.RequestContext, identity *middleware.ClientCertIdentity) (any, error) {
+			if len(identity.SPIFFEID) > 0 {
+				return "svc:" + identity.SPIFFEID[strings.LastIndexByte(identity.SPIFFEID, '/')+1:], nil
+			}
+			if identity.Subject.CommonName == "legacy-client" {
+				return "user:legacy", nil
+			}
+			return nil, nil
+		},
+	}))
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(serverCA.cert)
+
+	query := func(clientCert *tls.Certificate, expectedStatus int) string {
+		return queryWithClientCert(t, serverCAs, clientCert, expectedStatus)
+	}
+
+	workload := func(ca *testCA, spiffeID string) *tls.Certificate {
+		crt := ca.issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "billing"},
+			DNSNames:    []string{"billing.example.test"},
+			URIs:        []*url.URL{{Scheme: "spiffe", Host: "example.test", Path: spiffeID}},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		return &crt
+	}
+	user := func(commonName string) *tls.Certificate {
+		crt := clientCA.issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: commonName},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		return &crt
+	}
+
+	// Valid certificates are mapped by SPIFFE ID or subject
+	body := query(workload(clientCA, "/ns/prod/sa/billing"), 200)
+	if body != "svc:billing|billing|billing.example.test" {
+		t.Fatalf("unexpected identity [%v]", body)
+	}
+	body = query(user("legacy-client"), 200)
+	if body != "user:legacy|legacy-client|" {
+		t.Fatalf("unexpected identity [%v]", body)
+	}
+
+	// Missing certificate
+	query(nil, 401)
+
+	// Certificate issued by an untrusted authority
+	query(workload(untrustedCA, "/ns/prod/sa/billing"), 401)
+
+	// Identities rejected by the validator or the mapper
+	query(workload(clientCA, "/ns/staging/sa/billing"), 401)
+	query(user("unknown-client"), 401)
+}
+
+func TestMiddlewareClientCertAuthVerifiedChains(t *testing.T) {
+	serverCA := newTestCA(t, "server-ca")
+	clientCA := newTestCA(t, "client-ca")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{
+				serverCA.issue(t, &x509.Certificate{
+					Subject:     pkix.Name{CommonName: "127.0.0.1"},
+					IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+					ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+				}),
+			},
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  clientCAs,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/whoami", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString(middleware.GetClientCertIdentity(req).Subject.CommonName)
+		return nil
+	}, middleware.NewClientCertAuth(middleware.ClientCertAuthOptions{
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}))
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(serverCA.cert)
+
+	user := func(extKeyUsage ...x509.ExtKeyUsage) *tls.Certificate {
+		crt := clientCA.issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "mail-client"},
+			ExtKeyUsage: extKeyUsage,
+		})
+		return &crt
+	}
+
+	// The chain verified during the handshake is used but the key usages are still checked
+	body := queryWithClientCert(t, serverCAs, user(x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageEmailProtection), 200)
+	if body != "mail-client" {
+		t.Fatalf("unexpected identity [%v]", body)
+	}
+	queryWithClientCert(t, serverCAs, user(x509.ExtKeyUsageClientAuth), 401)
+
+	// Missing certificate
+	queryWithClientCert(t, serverCAs, nil, 401)
+}
+
+// -----------------------------------------------------------------------------
+
+func queryWithClientCert(t *testing.T, serverCAs *x509.CertPool, clientCert *tls.Certificate, expectedStatus int) string {
+	tlsConfig := &tls.Config{
+		RootCAs: serverCAs,
+	}
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		},
+		Timeout: 5 * time.Second,
+	}
+
+	resp, err := client.Get("https://127.0.0.1:3000/whoami")
+	if err != nil {
+		t.Fatalf("unable to query server [%v]", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != expectedStatus {
+		t.Fatalf("unexpected status code [got:%v / expected:%v]", resp.StatusCode, expectedStatus)
+	}
+	return string(body)
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key [%v]", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate [%v]", err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to parse certificate [%v]", err)
+	}
+	return &testCA{
+		cert: crt,
+		key:  key,
+	}
+}
+
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key [%v]", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("unable to create certificate [%v]", err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
@@ -0,0 +1,203 @@
+// See the LICENSE file for license details.
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/trusted_proxy"
+)
+
+// -----------------------------------------------------------------------------
+
+// RequestIDGenerator defines a function that creates a new request id.
+type RequestIDGenerator func() string
+
+// RequestIDOptions defines the behavior of the request id middleware.
+type RequestIDOptions struct {
+	// Header is the request header where inbound ids are read from. Defaults to X-Request-ID.
+	Header string
+
+	// ResponseHeader is the response header where the id is echoed. Defaults to the value of Header.
+	ResponseHeader string
+
+	// TrustedProxies is the list of IP addresses or CIDR ranges of the peers allowed to send an id. Inbound ids
+	// sent by other peers are replaced by a new one.
+	TrustedProxies []string
+
+	// TrustAll, if true, accepts inbound ids from any peer. Use it only if the server is not exposed to clients.
+	TrustAll bool
+
+	// Generator creates the ids of requests that do not have a trusted one. Defaults to NewUUIDv4.
+	Generator RequestIDGenerator
+
+	// MaxLength is the maximum length of an inbound id. Longer ids or the ones with non-printable characters are
+	// replaced by a new one. Defaults to 128.
+	MaxLength int
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultRequestIDMaxLength = 128
+
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	ksuidEpoch = 1400000000
+)
+
+// -----------------------------------------------------------------------------
+
+// NewRequestID creates a middleware that assigns an id to each request so it can be correlated across services.
+// The id is taken from the request header if the peer is trusted or generated otherwise. It is echoed in the
+// response and stored in the request context, see RequestContext.RequestID and webserver.RequestIDFromContext.
+func NewRequestID(opts RequestIDOptions) webserver.HandlerFunc {
+	var tp *trusted_proxy.TrustedProxy
+
+	if len(opts.Header) == 0 {
+		opts.Header = defaultRequestIDHeader
+	}
+	if len(opts.ResponseHeader) == 0 {
+		opts.ResponseHeader = opts.Header
+	}
+	if opts.Generator == nil {
+		opts.Generator = NewUUIDv4
+	}
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = defaultRequestIDMaxLength
+	}
+	if len(opts.TrustedProxies) > 0 {
+		tp = trusted_proxy.NewTrustedProxy(opts.TrustedProxies)
+	}
+
+	// Setup middleware function
+	return func(req *webserver.RequestContext) error {
+		var id string
+
+		if opts.TrustAll || (tp != nil && tp.IsIpTrusted(peerIP(req))) {
+			inboundID := req.RequestHeaders().Peek(opts.Header)
+			if isValidRequestID(inboundID, opts.MaxLength) {
+				id = string(inboundID)
+			}
+		}
+		if len(id) == 0 {
+			id = opts.Generator()
+		}
+
+		req.SetRequestID(id, opts.ResponseHeader)
+
+		// Go to next middleware
+		return req.Next()
+	}
+}
+
+// NewUUIDv4 creates a random UUID.
+func NewUUIDv4() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0F) | 0x40
+	b[8] = (b[8] & 0x3F) | 0x80
+	return formatUUID(b)
+}
+
+// NewUUIDv7 creates a time-ordered UUID.
+func NewUUIDv7() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[6:])
+	putUint48(b[:6], uint64(time.Now().UnixMilli()))
+	b[6] = (b[6] & 0x0F) | 0x70
+	b[8] = (b[8] & 0x3F) | 0x80
+	return formatUUID(b)
+}
+
+// NewULID creates a lexicographically sortable id encoded with the Crockford's base32 alphabet.
+func NewULID() string {
+	var b [16]byte
+	var dst [26]byte
+
+	_, _ = rand.Read(b[6:])
+	putUint48(b[:6], uint64(time.Now().UnixMilli()))
+
+	// Encode the 128 bits in 26 characters, 5 bits each, starting with the 2 most significant bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for idx := 25; idx >= 0; idx-- {
+		dst[idx] = crockfordAlphabet[lo&0x1F]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(dst[:])
+}
+
+// NewKSUID creates a K-sortable id encoded with the base62 alphabet.
+func NewKSUID() string {
+	var b [20]byte
+	var dst [27]byte
+
+	binary.BigEndian.PutUint32(b[:4], uint32(time.Now().Unix()-ksuidEpoch))
+	_, _ = rand.Read(b[4:])
+
+	n := new(big.Int).SetBytes(b[:])
+	base := big.NewInt(62)
+	rem := new(big.Int)
+	for idx := 26; idx >= 0; idx-- {
+		n.DivMod(n, base, rem)
+		dst[idx] = base62Alphabet[rem.Int64()]
+	}
+	return string(dst[:])
+}
+
+// -----------------------------------------------------------------------------
+
+// peerIP returns the address of the peer, ignoring forwarding headers.
+func peerIP(req *webserver.RequestContext) net.IP {
+	if addr, ok := req.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return net.IPv4zero
+}
+
+func isValidRequestID(id []byte, maxLength int) bool {
+	if len(id) == 0 || len(id) > maxLength {
+		return false
+	}
+	for _, ch := range id {
+		if ch < 0x21 || ch > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+func formatUUID(b [16]byte) string {
+	var dst [36]byte
+
+	hex.Encode(dst[0:8], b[0:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:13], b[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:18], b[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:23], b[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:], b[10:])
+	return string(dst[:])
+}
+
+func putUint48(dst []byte, v uint64) {
+	dst[0] = byte(v >> 40)
+	dst[1] = byte(v >> 32)
+	dst[2] = byte(v >> 24)
+	dst[3] = byte(v >> 16)
+	dst[4] = byte(v >> 8)
+	dst[5] = byte(v)
+}
@@ -0,0 +1,122 @@
+// See the LICENSE file for license details.
+
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+	"github.com/mxmauro/go-webserver/v2/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+var (
+	uuidV4Regex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	uuidV7Regex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+)
+
+// -----------------------------------------------------------------------------
+
+func TestMiddlewareRequestID(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		handler := func(req *webserver.RequestContext) error {
+			if webserver.RequestIDFromContext(req.UserContext()) != req.RequestID() {
+				return errors.New("request id not found in user context")
+			}
+			if req.QueryArgs().Has("fail") {
+				return webserver.NewHTTPError(http.StatusConflict, "item already exists")
+			}
+			_, _ = req.WriteString(req.RequestID())
+			return nil
+		}
+
+		srv.GET("/trusted", handler, middleware.NewRequestID(middleware.RequestIDOptions{
+			TrustedProxies: []string{"127.0.0.1"},
+		}))
+		srv.GET("/untrusted", handler, middleware.NewRequestID(middleware.RequestIDOptions{
+			Header:         "X-Correlation-ID",
+			TrustedProxies: []string{"10.0.0.0/8"},
+			Generator:      middleware.NewUUIDv7,
+		}))
+		return nil
+	})
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("X-Request-ID", "inbound-1")
+	headers.Set("X-Correlation-ID", "inbound-2")
+
+	// Inbound ids from trusted peers are kept
+	_, respHeaders, body, err := testcommon.QueryPath(http.MethodGet, "/trusted", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "inbound-1" || respHeaders.Get("X-Request-ID") != "inbound-1" {
+		t.Fatalf("inbound request id was not used [%v]", string(body))
+	}
+
+	// Errors keep the id
+	_, respHeaders, body, err = testcommon.QueryPath(http.MethodGet, "/trusted?fail", headers, nil, []int{409})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var problem map[string]any
+	err = json.Unmarshal(body, &problem)
+	if err != nil || problem["request_id"] != "inbound-1" || respHeaders.Get("X-Request-ID") != "inbound-1" {
+		t.Fatalf("request id not found in error response [%v]", string(body))
+	}
+
+	// Invalid ids are replaced
+	badHeaders := http.Header{}
+	badHeaders.Set("X-Request-ID", "with spaces")
+	_, _, body, err = testcommon.QueryPath(http.MethodGet, "/trusted", badHeaders, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !uuidV4Regex.Match(body) {
+		t.Fatalf("invalid request id was not replaced [%v]", string(body))
+	}
+
+	// Inbound ids from other peers are replaced
+	_, respHeaders, body, err = testcommon.QueryPath(http.MethodGet, "/untrusted", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !uuidV7Regex.Match(body) || respHeaders.Get("X-Correlation-ID") != string(body) {
+		t.Fatalf("untrusted request id was not replaced [%v]", string(body))
+	}
+}
+
+func TestRequestIDGenerators(t *testing.T) {
+	generators := []struct {
+		name string
+		gen  middleware.RequestIDGenerator
+		re   *regexp.Regexp
+	}{
+		{"uuidv4", middleware.NewUUIDv4, uuidV4Regex},
+		{"uuidv7", middleware.NewUUIDv7, uuidV7Regex},
+		{"ulid", middleware.NewULID, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+		{"ksuid", middleware.NewKSUID, regexp.MustCompile(`^[0-9A-Za-z]{27}$`)},
+	}
+
+	for _, g := range generators {
+		seen := make(map[string]struct{})
+		for idx := 0; idx < 100; idx++ {
+			id := g.gen()
+			if !g.re.MatchString(id) {
+				t.Fatalf("invalid %v id [%v]", g.name, id)
+			}
+			if _, ok := seen[id]; ok {
+				t.Fatalf("duplicated %v id [%v]", g.name, id)
+			}
+			seen[id] = struct{}{}
+		}
+	}
+}
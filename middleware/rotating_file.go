@@ -0,0 +1,291 @@
+// See the LICENSE file for license details.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// RotatingFileOptions defines the behavior of a rotating file.
+type RotatingFileOptions struct {
+	// Filename is the path of the file to write to. Old segments are stored in the same directory.
+	Filename string
+
+	// MaxSize is the size, in bytes, the file can reach before being rotated. Defaults to 100MB. Set a negative
+	// value to disable size based rotation.
+	MaxSize int64
+
+	// RotationInterval, if set, is the maximum amount of time a file is used before being rotated. It is checked
+	// on each write.
+	RotationInterval time.Duration
+
+	// MaxBackups is the number of old segments to keep. Zero keeps all of them.
+	MaxBackups int
+
+	// Compress, if true, gzips the old segments.
+	Compress bool
+}
+
+// RotatingFile is an io.WriteCloser that writes to a file and rotates it once it reaches a size or age. It is
+// safe for concurrent use.
+type RotatingFile struct {
+	mtx      sync.Mutex
+	opts     RotatingFileOptions
+	f        *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	// Timestamp of the last backup, used to keep names unique
+	lastBackupAt time.Time
+
+	// Serializes the compression and cleanup of old segments
+	bgMtx sync.Mutex
+	bgWg  sync.WaitGroup
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultRotatingFileMaxSize = 100 * 1048576 // 100MB
+
+	rotatingFileTimeFormat = "20060102T150405.000"
+)
+
+var (
+	ErrRotatingFileClosed = errors.New("rotating file closed")
+)
+
+// -----------------------------------------------------------------------------
+
+// NewRotatingFile opens or creates the file in append mode.
+func NewRotatingFile(opts RotatingFileOptions) (*RotatingFile, error) {
+	if len(opts.Filename) == 0 {
+		return nil, errors.New("invalid filename")
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = defaultRotatingFileMaxSize
+	}
+
+	rf := &RotatingFile{
+		opts: opts,
+	}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+
+	// Done
+	return rf, nil
+}
+
+// Write writes data to the file, rotating it before if needed.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mtx.Lock()
+	defer rf.mtx.Unlock()
+
+	if rf.closed {
+		return 0, ErrRotatingFileClosed
+	}
+
+	// A previous rotation could not open the new file, so try again
+	if rf.f == nil {
+		err := rf.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	mustRotate := rf.size > 0 && rf.opts.MaxSize > 0 && rf.size+int64(len(p)) > rf.opts.MaxSize
+	if rf.opts.RotationInterval > 0 && time.Since(rf.openedAt) >= rf.opts.RotationInterval {
+		mustRotate = true
+	}
+	if mustRotate {
+		err := rf.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it and opens a new one.
+func (rf *RotatingFile) Rotate() error {
+	rf.mtx.Lock()
+	defer rf.mtx.Unlock()
+
+	if rf.closed {
+		return ErrRotatingFileClosed
+	}
+	return rf.rotate()
+}
+
+// Close closes the file and waits until the old segments are compressed.
+func (rf *RotatingFile) Close() error {
+	rf.mtx.Lock()
+	if rf.closed {
+		rf.mtx.Unlock()
+		return nil
+	}
+	rf.closed = true
+	var err error
+	if rf.f != nil {
+		err = rf.f.Close()
+		rf.f = nil
+	}
+	rf.mtx.Unlock()
+
+	rf.bgWg.Wait()
+	return err
+}
+
+// -----------------------------------------------------------------------------
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.opts.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open file [err=%w]", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to open file [err=%w]", err)
+	}
+
+	rf.f = f
+	rf.size = info.Size()
+	rf.openedAt = time.Now()
+
+	// Done
+	return nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	// The handle is unusable after closing it even if it fails, so the next write will open the file again
+	if rf.f != nil {
+		err := rf.f.Close()
+		rf.f = nil
+		if err != nil {
+			return fmt.Errorf("unable to close file [err=%w]", err)
+		}
+	}
+
+	// The name has millisecond precision so avoid overwriting a backup if the file is rotated faster than that
+	backupAt := time.Now().Truncate(time.Millisecond)
+	if !backupAt.After(rf.lastBackupAt) {
+		backupAt = rf.lastBackupAt.Add(time.Millisecond)
+	}
+	rf.lastBackupAt = backupAt
+
+	ext := filepath.Ext(rf.opts.Filename)
+	backupName := strings.TrimSuffix(rf.opts.Filename, ext) + "-" + backupAt.Format(rotatingFileTimeFormat) + ext
+	err := os.Rename(rf.opts.Filename, backupName)
+	if err != nil {
+		// Keep writing to the current file
+		_ = rf.open()
+		return fmt.Errorf("unable to rename file [err=%w]", err)
+	}
+
+	err = rf.open()
+	if err != nil {
+		return err
+	}
+
+	rf.bgWg.Add(1)
+	go func() {
+		defer rf.bgWg.Done()
+
+		rf.bgMtx.Lock()
+		defer rf.bgMtx.Unlock()
+
+		if rf.opts.Compress {
+			_ = compressFile(backupName)
+		}
+		rf.removeOldBackups()
+	}()
+
+	// Done
+	return nil
+}
+
+func (rf *RotatingFile) removeOldBackups() {
+	if rf.opts.MaxBackups <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(rf.opts.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(rf.opts.Filename, ext)) + "-"
+	dir := filepath.Dir(rf.opts.Filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	backups := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], ".gz"), ext)
+		if _, err = time.Parse(rotatingFileTimeFormat, ts); err == nil {
+			backups = append(backups, name)
+		}
+	}
+
+	// The timestamp format sorts by date
+	sort.Strings(backups)
+	for idx := 0; idx < len(backups)-rf.opts.MaxBackups; idx++ {
+		_ = os.Remove(filepath.Join(dir, backups[idx]))
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+
+	_ = src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,261 @@
+// See the LICENSE file for license details.
+
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// TimeoutOptions defines the behavior of the timeout middleware.
+type TimeoutOptions struct {
+	// Timeout is the maximum amount of time the rest of the handler chain can take to process a request.
+	Timeout time.Duration
+
+	// StatusCode is the status to send when the timeout expires, for e.g., 504 for gateways. Defaults to 503.
+	StatusCode int
+
+	// Message is the body to send when the timeout expires. Defaults to the status text.
+	Message string
+}
+
+type timeoutState struct {
+	mtx        sync.Mutex
+	ctx        *timeoutContext
+	statusCode int
+	message    string
+}
+
+// timeoutContext is a context whose deadline can be changed until it expires.
+type timeoutContext struct {
+	parent    context.Context
+	mtx       sync.Mutex
+	deadline  time.Time
+	timer     *time.Timer
+	done      chan struct{}
+	err       error
+	stopWatch func() bool
+}
+
+type timeoutStateKey struct{}
+
+type timeoutResult struct {
+	err        error
+	panicValue any
+	stack      []byte
+}
+
+// -----------------------------------------------------------------------------
+
+// NewTimeout creates a middleware that limits the time the rest of the handler chain can take to process a
+// request. The request's user context gets a deadline so handlers can abort long operations, like database
+// queries, when its Done channel is closed. If the deadline passes, the configured response is sent to the client
+// and anything written by the handler afterward is discarded.
+//
+// If a timeout middleware is already running for the request, for e.g., a server-wide one, the new one replaces
+// its deadline and response, so it can be used in routes or groups to override the default timeout.
+//
+// When the timeout expires, the handler chain keeps running in background until it returns and any error or panic
+// it raises is logged. Middlewares placed before this one get the response that was sent from
+// RequestContext.Response, but the state set by the rest of the chain, like the matched route, is lost. Also, the
+// background chain keeps using the same underlying request, so these middlewares must only read it and not
+// modify the request or write to the response.
+func NewTimeout(opts TimeoutOptions) webserver.HandlerFunc {
+	if opts.Timeout <= 0 {
+		panic("invalid timeout")
+	}
+	if opts.StatusCode == 0 {
+		opts.StatusCode = http.StatusServiceUnavailable
+	}
+	if len(opts.Message) == 0 {
+		opts.Message = http.StatusText(opts.StatusCode)
+	}
+
+	// Setup middleware function
+	return func(req *webserver.RequestContext) error {
+		// Override the current timeout, if any
+		if st, ok := req.UserValue(timeoutStateKey{}).(*timeoutState); ok {
+			if st.override(opts) {
+				return req.Next()
+			}
+			return nil
+		}
+
+		st := &timeoutState{
+			ctx:        newTimeoutContext(req.UserContext(), opts.Timeout),
+			statusCode: opts.StatusCode,
+			message:    opts.Message,
+		}
+		req.SetUserContext(st.ctx)
+		req.SetUserValue(timeoutStateKey{}, st)
+
+		// Run the rest of the chain in background
+		detachedReq, release := req.Detach()
+		done := make(chan timeoutResult, 1)
+		go func() {
+			var result timeoutResult
+
+			func() {
+				defer func() {
+					// Forward panics so they can be recovered by previous middlewares
+					if r := recover(); r != nil {
+						result.panicValue = r
+						result.stack = debug.Stack()
+					}
+				}()
+
+				result.err = detachedReq.Next()
+			}()
+
+			done <- result
+		}()
+
+		select {
+		case result := <-done:
+			st.ctx.cancel(context.Canceled)
+			req.Attach(detachedReq)
+			return result.get()
+
+		case <-st.ctx.Done():
+			statusCode, message := st.response()
+
+			resp := fasthttp.AcquireResponse()
+			resp.SetStatusCode(statusCode)
+			resp.Header.SetContentType("text/plain; charset=utf-8")
+			resp.SetBodyString(message)
+			req.TimeoutErrorWithResponse(resp)
+			fasthttp.ReleaseResponse(resp)
+
+			// Release the copy once the rest of the chain returns. Nobody else will see its error or panic, so log
+			// them.
+			go func() {
+				(<-done).log(detachedReq)
+				release()
+			}()
+			return nil
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (r timeoutResult) get() error {
+	if r.panicValue != nil {
+		panic(r.panicValue)
+	}
+	return r.err
+}
+
+func (r timeoutResult) log(req *webserver.RequestContext) {
+	if r.panicValue != nil {
+		if r.panicValue != http.ErrAbortHandler {
+			req.Log().Error("handler panicked after the request timed out", slog.Any("panic", r.panicValue),
+				slog.String("stack", string(r.stack)))
+		}
+	} else if r.err != nil {
+		req.Log().Error("handler failed after the request timed out", slog.Any("error", r.err))
+	}
+}
+
+func (st *timeoutState) override(opts TimeoutOptions) bool {
+	if !st.ctx.reset(opts.Timeout) {
+		return false
+	}
+
+	st.mtx.Lock()
+	st.statusCode = opts.StatusCode
+	st.message = opts.Message
+	st.mtx.Unlock()
+	return true
+}
+
+func (st *timeoutState) response() (int, string) {
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+
+	return st.statusCode, st.message
+}
+
+// -----------------------------------------------------------------------------
+
+func newTimeoutContext(parent context.Context, timeout time.Duration) *timeoutContext {
+	ctx := &timeoutContext{
+		parent:   parent,
+		deadline: time.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+
+	// Hold the lock so cancel does not run until the context is fully initialized
+	ctx.mtx.Lock()
+	ctx.timer = time.AfterFunc(timeout, func() {
+		ctx.cancel(context.DeadlineExceeded)
+	})
+	ctx.stopWatch = context.AfterFunc(parent, func() {
+		ctx.cancel(parent.Err())
+	})
+	ctx.mtx.Unlock()
+
+	// Done
+	return ctx
+}
+
+func (ctx *timeoutContext) Deadline() (time.Time, bool) {
+	ctx.mtx.Lock()
+	defer ctx.mtx.Unlock()
+
+	return ctx.deadline, true
+}
+
+func (ctx *timeoutContext) Done() <-chan struct{} {
+	return ctx.done
+}
+
+func (ctx *timeoutContext) Err() error {
+	ctx.mtx.Lock()
+	defer ctx.mtx.Unlock()
+
+	return ctx.err
+}
+
+func (ctx *timeoutContext) Value(key any) any {
+	return ctx.parent.Value(key)
+}
+
+// reset changes the deadline. It returns false if the context is already done.
+func (ctx *timeoutContext) reset(timeout time.Duration) bool {
+	ctx.mtx.Lock()
+	defer ctx.mtx.Unlock()
+
+	if ctx.err != nil || !ctx.timer.Stop() {
+		return false
+	}
+	ctx.deadline = time.Now().Add(timeout)
+	ctx.timer.Reset(timeout)
+	return true
+}
+
+func (ctx *timeoutContext) cancel(err error) {
+	if err == nil {
+		err = context.Canceled
+	}
+
+	ctx.mtx.Lock()
+	defer ctx.mtx.Unlock()
+
+	if ctx.err != nil {
+		return
+	}
+	ctx.err = err
+	close(ctx.done)
+	ctx.timer.Stop()
+	ctx.stopWatch()
+}
@@ -0,0 +1,239 @@
+// See the LICENSE file for license details.
+
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+	"github.com/mxmauro/go-webserver/v2/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestMiddlewareTimeout(t *testing.T) {
+	aborted := make(chan error, 1)
+
+	sleep := func(req *webserver.RequestContext, d time.Duration) error {
+		ctx := req.UserContext()
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("context has no deadline")
+		}
+		select {
+		case <-time.After(d):
+			req.Success()
+			return nil
+		case <-ctx.Done():
+			aborted <- ctx.Err()
+			return ctx.Err()
+		}
+	}
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(middleware.NewTimeout(middleware.TimeoutOptions{
+			Timeout: 200 * time.Millisecond,
+		}))
+
+		srv.GET("/fast", func(req *webserver.RequestContext) error {
+			return sleep(req, 0)
+		})
+		srv.GET("/slow", func(req *webserver.RequestContext) error {
+			return sleep(req, 2*time.Second)
+		})
+
+		longTimeout := middleware.NewTimeout(middleware.TimeoutOptions{
+			Timeout:    600 * time.Millisecond,
+			StatusCode: http.StatusGatewayTimeout,
+			Message:    "upstream took too long",
+		})
+		srv.GET("/report", func(req *webserver.RequestContext) error {
+			return sleep(req, 400*time.Millisecond)
+		}, longTimeout)
+		srv.GET("/report/slow", func(req *webserver.RequestContext) error {
+			return sleep(req, 2*time.Second)
+		}, longTimeout)
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	query := func(path string, expectedStatus int, expectedBody string, maxDuration time.Duration) {
+		start := time.Now()
+		_, _, body, err := testcommon.QueryPath(http.MethodGet, path, nil, nil, []int{expectedStatus})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(expectedBody) > 0 && string(body) != expectedBody {
+			t.Fatalf("unexpected body [got:%v / expected:%v]", string(body), expectedBody)
+		}
+		if elapsed := time.Since(start); elapsed > maxDuration {
+			t.Fatalf("request to %v took too long [%v]", path, elapsed)
+		}
+	}
+
+	query("/fast", 200, "", time.Second)
+
+	query("/slow", 503, "Service Unavailable", time.Second)
+	select {
+	case err := <-aborted:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected context error [%v]", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("handler was not aborted")
+	}
+
+	// Route overrides
+	query("/report", 200, "", time.Second)
+	query("/report/slow", 504, "upstream took too long", time.Second)
+	<-aborted
+}
+
+func TestMiddlewareTimeoutWithAccessLog(t *testing.T) {
+	output := &syncBuffer{}
+	slowDone := make(chan struct{})
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(middleware.NewAccessLog(middleware.AccessLogOptions{
+			Format: middleware.AccessLogJSON,
+			Output: output,
+		}))
+		srv.Use(middleware.NewTimeout(middleware.TimeoutOptions{
+			Timeout: 200 * time.Millisecond,
+		}))
+		srv.Use(middleware.NewRequestID(middleware.RequestIDOptions{}))
+
+		srv.GET("/items/{id}", func(req *webserver.RequestContext) error {
+			_, _ = req.WriteString("item")
+			return nil
+		})
+		srv.GET("/fail", func(req *webserver.RequestContext) error {
+			return webserver.NewHTTPError(http.StatusConflict, "item already exists")
+		})
+		srv.GET("/slow", func(req *webserver.RequestContext) error {
+			defer close(slowDone)
+
+			// Keep writing the response after the timeout expires
+			for idx := 0; idx < 40; idx++ {
+				req.SetStatusCode(http.StatusOK)
+				_, _ = req.WriteString("x")
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	_, headers, _, err := testcommon.QueryPath(http.MethodGet, "/items/1", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	itemRequestID := headers.Get("X-Request-ID")
+	_, headers, body, err := testcommon.QueryPath(http.MethodGet, "/fail", nil, nil, []int{409})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	failRequestID := headers.Get("X-Request-ID")
+	var problem map[string]any
+	err = json.Unmarshal(body, &problem)
+	if err != nil || len(failRequestID) == 0 || problem["request_id"] != failRequestID {
+		t.Fatalf("request id not found in error response [%v]", string(body))
+	}
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/slow", nil, nil, []int{503})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	<-slowDone
+
+	// The state set by the detached chain must reach the access log, unless the timeout expired
+	lines := output.lines()
+	if len(lines) != 3 {
+		t.Fatalf("unexpected number of entries [%d]", len(lines))
+	}
+	var entry map[string]any
+	err = json.Unmarshal([]byte(lines[0]), &entry)
+	if err != nil || entry["route"] != "/items/{id}" || len(itemRequestID) == 0 || entry["request_id"] != itemRequestID {
+		t.Fatalf("unexpected entry [%v]", lines[0])
+	}
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	if err != nil || entry["status"] != float64(409) || entry["request_id"] != failRequestID {
+		t.Fatalf("unexpected entry [%v]", lines[1])
+	}
+	err = json.Unmarshal([]byte(lines[2]), &entry)
+	if err != nil || entry["status"] != float64(503) || entry["bytes_out"] != float64(len("Service Unavailable")) {
+		t.Fatalf("unexpected entry [%v]", lines[2])
+	}
+}
+
+func TestMiddlewareTimeoutLogsLateErrors(t *testing.T) {
+	output := &syncBuffer{}
+	handlersDone := make(chan struct{}, 2)
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		Logger:  slog.New(slog.NewJSONHandler(output, nil)),
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.Use(middleware.NewTimeout(middleware.TimeoutOptions{
+		Timeout: 100 * time.Millisecond,
+	}))
+	srv.GET("/error", func(req *webserver.RequestContext) error {
+		defer func() {
+			handlersDone <- struct{}{}
+		}()
+		time.Sleep(300 * time.Millisecond)
+		return errors.New("late failure")
+	})
+	srv.GET("/panic", func(req *webserver.RequestContext) error {
+		defer func() {
+			handlersDone <- struct{}{}
+		}()
+		time.Sleep(300 * time.Millisecond)
+		panic("late panic")
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	for _, path := range []string{"/error", "/panic"} {
+		_, _, _, err = testcommon.QueryPath(http.MethodGet, path, nil, nil, []int{503})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	<-handlersDone
+	<-handlersDone
+
+	// The handlers finish before the results are logged, so wait a little
+	for retry := 0; len(output.lines()) < 2 && retry < 50; retry++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lines := output.lines()
+	if len(lines) != 2 {
+		t.Fatalf("unexpected number of log entries [%d]", len(lines))
+	}
+	if !strings.Contains(lines[0], "late failure") || !strings.Contains(lines[1], "late panic") {
+		t.Fatalf("unexpected log entries [%v]", lines)
+	}
+}
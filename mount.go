@@ -0,0 +1,136 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bytes"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// Mount delegates the requests whose path starts with the given prefix to another server. The prefix is removed
+// from the request path before running the sub-server's middlewares and routing the request with its route
+// table, including its virtual hosts, so a server built and tested on its own can be served from the same port.
+//
+// The server-wide middlewares of this server are executed first, followed by the sub-server's ones. Errors
+// returned by the sub-server's handlers are processed by its own request error handler. The sub-server must
+// not be started.
+func (srv *Server) Mount(prefix string, sub *Server) *Route {
+	if sub == nil || sub == srv {
+		panic("invalid server to mount")
+	}
+	prefix = joinGroupPrefix("", prefix)
+	if prefix == "/" {
+		panic("invalid mount prefix")
+	}
+
+	// The sub-server's routes will be used concurrently from now on
+	sub.setRoutesLive()
+
+	// Close the sub-server's WebSocket connections when this server is stopped
+	srv.webSockets.addMounted(sub)
+
+	return srv.defaultRoutes.addRoute(router.MethodWild, prefix+"/"+serveFilesSuffix, func(req *RequestContext) error {
+		return sub.serveMounted(req, prefix)
+	}, nil, nil)
+}
+
+// -----------------------------------------------------------------------------
+
+func (srv *Server) serveMounted(req *RequestContext, prefix string) error {
+	uri := req.ctx.Request.URI()
+
+	// Save the state of the parent's chain
+	saved := *req
+	origPath := append([]byte(nil), uri.PathOriginal()...)
+
+	// Strip the prefix
+	path := uri.Path()
+	if bytes.HasPrefix(path, []byte(prefix)) {
+		path = path[len(prefix):]
+	}
+	if len(path) == 0 || path[0] != '/' {
+		path = append([]byte("/"), path...)
+	}
+	uri.SetPathBytes(path)
+
+	// Run the sub-server's chain
+	rt := srv.selectRouteTable(req)
+	routerHandler := rt.router.Load().Handler
+
+	fullPrefix := saved.pathPrefix + prefix
+
+	req.srv = srv
+	req.pathPrefix = fullPrefix
+	req.logger = nil
+	req.middlewareIndex = 0
+	req.srvRouterHandler = func(ctx *fasthttp.RequestCtx) {
+		routerHandler(ctx)
+		fixMountedRedirect(ctx, fullPrefix)
+	}
+	req.srvMiddlewares = *rt.chain.Load()
+	req.srvMiddlewaresLen = len(req.srvMiddlewares)
+	req.setHandlerParams(nil, nil, nil, nil)
+
+	err := req.Next()
+	if err != nil {
+		srv.requestErrorHandler(req, err)
+	}
+
+	// Restore the parent's state but keep the sub-server's route and request id so they can be logged
+	uri.SetPathBytes(origPath)
+	userCtx := req.userCtx
+	route := req.route
+	routePattern := req.routePattern
+	requestID := req.requestID
+	requestIDHeader := req.requestIDHeader
+	*req = saved
+	req.userCtx = userCtx
+	if route != nil {
+		req.route = route
+		req.routePattern = routePattern
+	}
+	if requestID != saved.requestID {
+		req.requestID = requestID
+		req.requestIDHeader = requestIDHeader
+		req.logger = nil
+	}
+
+	// Done
+	return nil
+}
+
+// fixMountedRedirect adds the mount prefix to the redirections generated by the sub-server's router, for e.g.,
+// when a trailing slash is removed.
+func fixMountedRedirect(ctx *fasthttp.RequestCtx, prefix string) {
+	status := ctx.Response.StatusCode()
+	if status != fasthttp.StatusMovedPermanently && status != fasthttp.StatusPermanentRedirect {
+		return
+	}
+	location := ctx.Response.Header.Peek(fasthttp.HeaderLocation)
+	if len(location) == 0 {
+		return
+	}
+
+	if location[0] == '/' {
+		if len(location) > 1 && location[1] == '/' {
+			return // Protocol-relative url
+		}
+		ctx.Response.Header.Set(fasthttp.HeaderLocation, prefix+string(location))
+		return
+	}
+
+	u := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(u)
+
+	// The router builds absolute urls using the request uri
+	err := u.Parse(nil, location)
+	if err != nil || !bytes.Equal(u.Host(), ctx.URI().Host()) {
+		return
+	}
+	u.SetPathBytes(append([]byte(prefix), u.Path()...))
+	ctx.Response.Header.SetBytesV(fasthttp.HeaderLocation, u.FullURI())
+}
@@ -0,0 +1,390 @@
+// See the LICENSE file for license details.
+
+package proxy_protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxmauro/go-webserver/v2/trusted_proxy"
+)
+
+// -----------------------------------------------------------------------------
+
+// Options specifies how PROXY protocol headers are handled.
+type Options struct {
+	// TrustedSources is the list of IP addresses or CIDR ranges allowed to send a PROXY protocol header. Connections
+	// from other sources are used as-is.
+	TrustedSources []string
+
+	// HeaderTimeout is the maximum amount of time to wait for the PROXY protocol header. Defaults to 5 seconds.
+	HeaderTimeout time.Duration
+
+	// If Required is true, connections from trusted sources without a PROXY protocol header are rejected.
+	Required bool
+}
+
+// TLV is a type-length-value vector sent along a PROXY protocol v2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Listener wraps a net.Listener and parses the PROXY protocol header of the accepted connections.
+type Listener struct {
+	net.Listener
+	tp            *trusted_proxy.TrustedProxy
+	headerTimeout time.Duration
+	required      bool
+}
+
+// Conn is a connection accepted by a PROXY protocol listener. The header is parsed lazily on the first read or
+// address query so the listener's accept loop is never blocked.
+type Conn struct {
+	net.Conn
+	l          *Listener
+	once       sync.Once
+	reader     *bufio.Reader
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	tlvs       []TLV
+
+	deadlineMtx  sync.Mutex
+	readDeadline time.Time
+	headerRead   bool
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	// TLV types defined in the PROXY protocol specification
+	TLVTypeALPN      byte = 0x01
+	TLVTypeAuthority byte = 0x02
+	TLVTypeCRC32C    byte = 0x03
+	TLVTypeNoop      byte = 0x04
+	TLVTypeUniqueID  byte = 0x05
+	TLVTypeSSL       byte = 0x20
+	TLVTypeNetNS     byte = 0x30
+
+	DefaultHeaderTimeout = 5 * time.Second
+
+	v1MaxHeaderLength = 107
+	v2HeaderLength    = 16
+)
+
+var (
+	v1Signature = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrNoProxyHeader      = errors.New("proxy protocol header not found")
+	ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+)
+
+// -----------------------------------------------------------------------------
+
+// NewListener wraps the given listener with a PROXY protocol v1/v2 parser.
+func NewListener(ln net.Listener, opts Options) *Listener {
+	headerTimeout := opts.HeaderTimeout
+	if headerTimeout <= 0 {
+		headerTimeout = DefaultHeaderTimeout
+	}
+	return &Listener{
+		Listener:      ln,
+		tp:            trusted_proxy.NewTrustedProxy(opts.TrustedSources),
+		headerTimeout: headerTimeout,
+		required:      opts.Required,
+	}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only parse headers sent by trusted sources
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !l.tp.IsIpTrusted(tcpAddr.IP) {
+		return conn, nil
+	}
+
+	return &Conn{
+		Conn: conn,
+		l:    l,
+	}, nil
+}
+
+// Read reads data from the connection, after the PROXY protocol header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.parseHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the PROXY protocol header or the connection's remote address if
+// not available.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.parseHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address sent in the PROXY protocol header or the connection's local address
+// if not available.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.parseHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the connection. See SetReadDeadline.
+func (c *Conn) SetDeadline(t time.Time) error {
+	err := c.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection. If the PROXY protocol header was not read yet, the
+// deadline is applied once it is, and it also limits the time to wait for the header.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.deadlineMtx.Lock()
+	defer c.deadlineMtx.Unlock()
+
+	c.readDeadline = t
+	if !c.headerRead {
+		return nil
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+// TLVs returns the type-length-value vectors sent in a PROXY protocol v2 header.
+func (c *Conn) TLVs() []TLV {
+	c.once.Do(c.parseHeader)
+	return c.tlvs
+}
+
+// TLV returns the value of the first vector of the given type.
+func (c *Conn) TLV(t byte) ([]byte, bool) {
+	for _, tlv := range c.TLVs() {
+		if tlv.Type == t {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// -----------------------------------------------------------------------------
+
+func (c *Conn) parseHeader() {
+	c.reader = bufio.NewReader(c.Conn)
+
+	// Do not wait for the header beyond the deadline set by the caller, if any
+	c.deadlineMtx.Lock()
+	deadline := time.Now().Add(c.l.headerTimeout)
+	if !c.readDeadline.IsZero() && c.readDeadline.Before(deadline) {
+		deadline = c.readDeadline
+	}
+	c.deadlineMtx.Unlock()
+
+	c.err = c.Conn.SetReadDeadline(deadline)
+	if c.err == nil {
+		c.err = c.readHeader()
+	}
+
+	// Restore the deadline set by the caller
+	c.deadlineMtx.Lock()
+	c.headerRead = true
+	if c.err == nil {
+		c.err = c.Conn.SetReadDeadline(c.readDeadline)
+	}
+	c.deadlineMtx.Unlock()
+}
+
+func (c *Conn) readHeader() error {
+	// Check the signature
+	sig, err := c.reader.Peek(len(v1Signature))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(sig, v1Signature) {
+		return c.readV1Header()
+	}
+	if sig[0] == v2Signature[0] {
+		sig, err = c.reader.Peek(len(v2Signature))
+		if err == nil && bytes.Equal(sig, v2Signature) {
+			return c.readV2Header()
+		}
+	}
+
+	// No header
+	if c.l.required {
+		return ErrNoProxyHeader
+	}
+	return nil
+}
+
+func (c *Conn) readV1Header() error {
+	line := make([]byte, 0, v1MaxHeaderLength)
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= v1MaxHeaderLength {
+			return ErrInvalidProxyHeader
+		}
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return ErrInvalidProxyHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return ErrInvalidProxyHeader
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil
+
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return ErrInvalidProxyHeader
+		}
+		srcIP := net.ParseIP(fields[2])
+		dstIP := net.ParseIP(fields[3])
+		srcPort, err := strconv.ParseUint(fields[4], 10, 16)
+		if err != nil {
+			return ErrInvalidProxyHeader
+		}
+		dstPort, err := strconv.ParseUint(fields[5], 10, 16)
+		if err != nil || srcIP == nil || dstIP == nil {
+			return ErrInvalidProxyHeader
+		}
+		if (fields[1] == "TCP4") != (srcIP.To4() != nil) {
+			return ErrInvalidProxyHeader
+		}
+
+		c.remoteAddr = &net.TCPAddr{
+			IP:   srcIP,
+			Port: int(srcPort),
+		}
+		c.localAddr = &net.TCPAddr{
+			IP:   dstIP,
+			Port: int(dstPort),
+		}
+		return nil
+	}
+
+	return ErrInvalidProxyHeader
+}
+
+func (c *Conn) readV2Header() error {
+	var hdr [v2HeaderLength]byte
+
+	_, err := io.ReadFull(c.reader, hdr[:])
+	if err != nil {
+		return err
+	}
+
+	// Check version and command
+	if hdr[12]>>4 != 2 {
+		return ErrInvalidProxyHeader
+	}
+	command := hdr[12] & 0x0F
+	if command > 1 {
+		return ErrInvalidProxyHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	_, err = io.ReadFull(c.reader, payload)
+	if err != nil {
+		return err
+	}
+
+	// LOCAL command, for e.g., health checks from the proxy itself. Keep original addresses.
+	if command == 0 {
+		return nil
+	}
+
+	// Parse addresses
+	var addrLen int
+	switch hdr[13] >> 4 {
+	case 0x0: // AF_UNSPEC
+		addrLen = 0
+
+	case 0x1: // AF_INET
+		addrLen = 12
+		if len(payload) < addrLen {
+			return ErrInvalidProxyHeader
+		}
+		c.setV2Addresses(payload[0:4], payload[4:8], payload[8:10], payload[10:12])
+
+	case 0x2: // AF_INET6
+		addrLen = 36
+		if len(payload) < addrLen {
+			return ErrInvalidProxyHeader
+		}
+		c.setV2Addresses(payload[0:16], payload[16:32], payload[32:34], payload[34:36])
+
+	case 0x3: // AF_UNIX
+		addrLen = 216
+		if len(payload) < addrLen {
+			return ErrInvalidProxyHeader
+		}
+
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	// Parse TLVs
+	tlvs := payload[addrLen:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return ErrInvalidProxyHeader
+		}
+		l := int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if len(tlvs) < 3+l {
+			return ErrInvalidProxyHeader
+		}
+		c.tlvs = append(c.tlvs, TLV{
+			Type:  tlvs[0],
+			Value: tlvs[3 : 3+l],
+		})
+		tlvs = tlvs[3+l:]
+	}
+
+	// Done
+	return nil
+}
+
+func (c *Conn) setV2Addresses(srcIP []byte, dstIP []byte, srcPort []byte, dstPort []byte) {
+	c.remoteAddr = &net.TCPAddr{
+		IP:   append(make(net.IP, 0, len(srcIP)), srcIP...),
+		Port: int(binary.BigEndian.Uint16(srcPort)),
+	}
+	c.localAddr = &net.TCPAddr{
+		IP:   append(make(net.IP, 0, len(dstIP)), dstIP...),
+		Port: int(binary.BigEndian.Uint16(dstPort)),
+	}
+}
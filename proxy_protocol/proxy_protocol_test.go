@@ -0,0 +1,100 @@
+// See the LICENSE file for license details.
+
+package proxy_protocol_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/mxmauro/go-webserver/v2/proxy_protocol"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestProxyProtocolV1(t *testing.T) {
+	conn := acceptWithHeader(t, []byte("PROXY TCP4 192.0.2.10 198.51.100.1 56324 443\r\n"))
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if conn.RemoteAddr().String() != "192.0.2.10:56324" {
+		t.Fatalf("unexpected remote address [%v]", conn.RemoteAddr())
+	}
+	if conn.LocalAddr().String() != "198.51.100.1:443" {
+		t.Fatalf("unexpected local address [%v]", conn.LocalAddr())
+	}
+	checkPayload(t, conn)
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	hdr := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	hdr = append(hdr, 0x21, 0x11) // Version 2 + PROXY command, AF_INET + STREAM
+
+	payload := []byte{192, 0, 2, 10, 198, 51, 100, 1, 0xDC, 0x04, 0x01, 0xBB}
+	payload = append(payload, proxy_protocol.TLVTypeAuthority, 0, 11)
+	payload = append(payload, []byte("example.com")...)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(payload)))
+	hdr = append(hdr, payload...)
+
+	conn := acceptWithHeader(t, hdr)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if conn.RemoteAddr().String() != "192.0.2.10:56324" {
+		t.Fatalf("unexpected remote address [%v]", conn.RemoteAddr())
+	}
+	authority, ok := conn.TLV(proxy_protocol.TLVTypeAuthority)
+	if !ok || string(authority) != "example.com" {
+		t.Fatalf("unexpected authority TLV [%v]", string(authority))
+	}
+	checkPayload(t, conn)
+}
+
+// -----------------------------------------------------------------------------
+
+func acceptWithHeader(t *testing.T, header []byte) *proxy_protocol.Conn {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to create listener [%v]", err)
+	}
+	ppLn := proxy_protocol.NewListener(ln, proxy_protocol.Options{
+		TrustedSources: []string{"127.0.0.0/8"},
+		Required:       true,
+	})
+	defer func() {
+		_ = ppLn.Close()
+	}()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	go func() {
+		_, _ = client.Write(append(header, []byte("payload")...))
+		_ = client.Close()
+	}()
+
+	conn, err := ppLn.Accept()
+	if err != nil {
+		t.Fatalf("unable to accept connection [%v]", err)
+	}
+	ppConn, ok := conn.(*proxy_protocol.Conn)
+	if !ok {
+		t.Fatalf("accepted connection is not a proxy protocol connection")
+	}
+	return ppConn
+}
+
+func checkPayload(t *testing.T, conn net.Conn) {
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("unable to read payload [%v]", err)
+	}
+	if !bytes.Equal(data, []byte("payload")) {
+		t.Fatalf("unexpected payload [%v]", string(data))
+	}
+}
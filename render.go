@@ -0,0 +1,303 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// -----------------------------------------------------------------------------
+
+// Encoder serializes a value into the response body.
+type Encoder func(w io.Writer, v any) error
+
+type registeredEncoder struct {
+	mediaType   string
+	contentType string
+	encoder     Encoder
+}
+
+type acceptedMediaType struct {
+	mediaType string
+	quality   float64
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	MediaTypeJSON        = "application/json"
+	MediaTypeXML         = "application/xml"
+	MediaTypeMessagePack = "application/msgpack"
+	MediaTypeCBOR        = "application/cbor"
+	MediaTypeTextPlain   = "text/plain"
+	MediaTypeNDJSON      = "application/x-ndjson"
+	MediaTypeHTML        = "text/html"
+	MediaTypeProblemJSON = "application/problem+json"
+)
+
+// -----------------------------------------------------------------------------
+
+var (
+	encodersMtx = sync.RWMutex{}
+	encoders    = []registeredEncoder{
+		newRegisteredEncoder(MediaTypeJSON, encodeJSON),
+		newRegisteredEncoder(MediaTypeXML, encodeXML),
+		newRegisteredEncoder(MediaTypeMessagePack, encodeMessagePack),
+		newRegisteredEncoder(MediaTypeCBOR, encodeCBOR),
+		newRegisteredEncoder(MediaTypeTextPlain, encodeTextPlain),
+		newRegisteredEncoder(MediaTypeNDJSON, encodeNDJSON),
+	}
+)
+
+// -----------------------------------------------------------------------------
+
+// RegisterEncoder adds an encoder, or replaces an existing one, for the specified media type to be used by
+// RequestContext.Render. When the client accepts any media type, the encoders are tried in registration order,
+// being JSON the first one.
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if len(mediaType) == 0 || !strings.Contains(mediaType, "/") || strings.Contains(mediaType, "*") {
+		panic("invalid media type")
+	}
+	if encoder == nil {
+		panic("invalid encoder")
+	}
+
+	encodersMtx.Lock()
+	defer encodersMtx.Unlock()
+
+	newEncoders := make([]registeredEncoder, 0, len(encoders)+1)
+	replaced := false
+	for _, e := range encoders {
+		if e.mediaType == mediaType {
+			e = newRegisteredEncoder(mediaType, encoder)
+			replaced = true
+		}
+		newEncoders = append(newEncoders, e)
+	}
+	if !replaced {
+		newEncoders = append(newEncoders, newRegisteredEncoder(mediaType, encoder))
+	}
+	encoders = newEncoders
+}
+
+// UnregisterEncoder removes the encoder of the specified media type, including the built-in ones.
+func UnregisterEncoder(mediaType string) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	encodersMtx.Lock()
+	defer encodersMtx.Unlock()
+
+	newEncoders := make([]registeredEncoder, 0, len(encoders))
+	for _, e := range encoders {
+		if e.mediaType != mediaType {
+			newEncoders = append(newEncoders, e)
+		}
+	}
+	encoders = newEncoders
+}
+
+// Render encodes the value using the media type that best matches the request's Accept header and sends it
+// with the specified status code. JSON is used if the header is missing. If no encoder matches the client
+// preferences, a 406 error is sent.
+//
+// Built-in encoders are JSON, XML, MessagePack, CBOR, plain text and NDJSON, which encodes each element of a
+// slice in its own line. Use RegisterEncoder to add custom ones.
+func (req *RequestContext) Render(status int, v any) error {
+	var buf bytes.Buffer
+
+	req.ctx.Response.Header.Add(fasthttp.HeaderVary, fasthttp.HeaderAccept)
+
+	enc, ok := negotiateEncoder(req.ctx.Request.Header.Peek(fasthttp.HeaderAccept))
+	if !ok {
+		req.sendError(fasthttp.StatusNotAcceptable, "")
+		return nil
+	}
+
+	err := enc.encoder(&buf, v)
+	if err != nil {
+		return fmt.Errorf("unable to encode %v output [err=%w]", enc.mediaType, err)
+	}
+
+	req.ctx.Response.Header.SetContentType(enc.contentType)
+	req.ctx.Response.SetStatusCode(status)
+	if !req.IsHead() {
+		req.ctx.Response.SetBody(buf.Bytes())
+	} else {
+		req.ctx.Response.ResetBody()
+	}
+
+	// Done
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func newRegisteredEncoder(mediaType string, encoder Encoder) registeredEncoder {
+	contentType := mediaType
+	if strings.HasPrefix(mediaType, "text/") || mediaType == MediaTypeJSON || mediaType == MediaTypeXML ||
+		mediaType == MediaTypeNDJSON {
+		contentType += "; charset=utf-8"
+	}
+	return registeredEncoder{
+		mediaType:   mediaType,
+		contentType: contentType,
+		encoder:     encoder,
+	}
+}
+
+func negotiateEncoder(accept []byte) (registeredEncoder, bool) {
+	encodersMtx.RLock()
+	currentEncoders := encoders
+	encodersMtx.RUnlock()
+
+	if len(currentEncoders) == 0 {
+		return registeredEncoder{}, false
+	}
+
+	accepted := parseAcceptHeader(string(accept))
+	if len(accepted) == 0 {
+		return currentEncoders[0], true
+	}
+
+	bestIdx := -1
+	bestQuality := 0.0
+	for idx, enc := range currentEncoders {
+		quality := mediaTypeQuality(enc.mediaType, accepted)
+		if quality > bestQuality {
+			bestIdx = idx
+			bestQuality = quality
+		}
+	}
+	if bestIdx < 0 {
+		return registeredEncoder{}, false
+	}
+	return currentEncoders[bestIdx], true
+}
+
+// parseAcceptHeader parses an Accept header and returns the media ranges sorted from the most specific to the
+// least one as required to compute the quality of a media type.
+func parseAcceptHeader(header string) []acceptedMediaType {
+	accepted := make([]acceptedMediaType, 0)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			if part != "*" {
+				continue
+			}
+			mediaType = "*/*" // Some clients send a single asterisk
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		accepted = append(accepted, acceptedMediaType{
+			mediaType: mediaType,
+			quality:   quality,
+		})
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return mediaRangeSpecificity(accepted[i].mediaType) > mediaRangeSpecificity(accepted[j].mediaType)
+	})
+
+	// Done
+	return accepted
+}
+
+func mediaRangeSpecificity(mediaRange string) int {
+	if mediaRange == "*/*" {
+		return 0
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return 1
+	}
+	return 2
+}
+
+// mediaTypeQuality returns the quality of the most specific media range that matches the given media type.
+func mediaTypeQuality(mediaType string, accepted []acceptedMediaType) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, a := range accepted {
+		if a.mediaType == mediaType || a.mediaType == "*/*" || a.mediaType == mainType+"/*" {
+			return a.quality
+		}
+	}
+	return 0
+}
+
+// -----------------------------------------------------------------------------
+
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func encodeXML(w io.Writer, v any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(v)
+	}
+	return err
+}
+
+func encodeMessagePack(w io.Writer, v any) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+func encodeCBOR(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+func encodeTextPlain(w io.Writer, v any) error {
+	var err error
+
+	switch t := v.(type) {
+	case string:
+		_, err = io.WriteString(w, t)
+	case []byte:
+		_, err = w.Write(t)
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+	return err
+}
+
+func encodeNDJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for idx := 0; idx < rv.Len(); idx++ {
+			err := enc.Encode(rv.Index(idx).Interface())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return enc.Encode(v)
+}
@@ -5,11 +5,14 @@ package go_webserver
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net"
 	"strings"
 
+	"github.com/mxmauro/go-webserver/v2/proxy_protocol"
 	"github.com/mxmauro/go-webserver/v2/trusted_proxy"
 	"github.com/mxmauro/go-webserver/v2/util"
 	"github.com/valyala/fasthttp"
@@ -32,17 +35,31 @@ var (
 // -----------------------------------------------------------------------------
 
 type RequestContext struct {
-	ctx     *fasthttp.RequestCtx
-	tp      *trusted_proxy.TrustedProxy
-	userCtx context.Context
-
-	middlewareIndex   int
-	srvRouterHandler  fasthttp.RequestHandler
-	srvMiddlewares    []HandlerFunc
-	srvMiddlewaresLen int
-	middlewares       []HandlerFunc
-	middlewaresLen    int
-	handler           HandlerFunc
+	ctx        *fasthttp.RequestCtx
+	srv        *Server
+	pool       *RequestContextPool
+	detached   bool
+	tp         *trusted_proxy.TrustedProxy
+	userCtx    context.Context
+	pathPrefix string
+
+	route           *Route
+	routePattern    string
+	logger          *slog.Logger
+	timeoutResponse *fasthttp.Response
+
+	requestID       string
+	requestIDHeader string
+
+	middlewareIndex     int
+	srvRouterHandler    fasthttp.RequestHandler
+	srvMiddlewares      []HandlerFunc
+	srvMiddlewaresLen   int
+	groupMiddlewares    []HandlerFunc
+	groupMiddlewaresLen int
+	middlewares         []HandlerFunc
+	middlewaresLen      int
+	handler             HandlerFunc
 }
 
 // -----------------------------------------------------------------------------
@@ -51,7 +68,13 @@ func (req *RequestContext) Request() *fasthttp.Request {
 	return &req.ctx.Request
 }
 
+// Response returns the response being built. If a response was already sent with TimeoutErrorWithResponse, for
+// e.g., by the timeout middleware, that one is returned instead because a detached handler can still be using the
+// original.
 func (req *RequestContext) Response() *fasthttp.Response {
+	if req.timeoutResponse != nil {
+		return req.timeoutResponse
+	}
 	return &req.ctx.Response
 }
 
@@ -60,7 +83,7 @@ func (req *RequestContext) RequestHeaders() *fasthttp.RequestHeader {
 }
 
 func (req *RequestContext) ResponseHeaders() *fasthttp.ResponseHeader {
-	return &req.ctx.Response.Header
+	return &req.Response().Header
 }
 
 func (req *RequestContext) RequestHeader(key string) string {
@@ -89,6 +112,9 @@ func (req *RequestContext) Error(msg string, statusCode int) {
 	for idx, value := range preservedHeaders {
 		req.ctx.Response.Header.SetBytesKV(preservedHeadersOnError[idx], value)
 	}
+	if len(req.requestIDHeader) > 0 {
+		req.ctx.Response.Header.Set(req.requestIDHeader, req.requestID)
+	}
 
 	// Set new status
 	req.ctx.SetStatusCode(statusCode)
@@ -166,14 +192,31 @@ func (req *RequestContext) CallFastHttpHandler(handler fasthttp.RequestHandler)
 	handler(req.ctx)
 }
 
+// TimeoutError sends a response with the specified message and a 408 status code, ignoring what the handler writes
+// afterward. See TimeoutErrorWithResponse.
+func (req *RequestContext) TimeoutError(msg string) {
+	req.TimeoutErrorWithCode(msg, fasthttp.StatusRequestTimeout)
+}
+
+// TimeoutErrorWithCode sends a response with the specified message and status code, ignoring what the handler
+// writes afterward. See TimeoutErrorWithResponse.
+func (req *RequestContext) TimeoutErrorWithCode(msg string, statusCode int) {
+	req.ctx.TimeoutErrorWithCode(msg, statusCode)
+	req.timeoutResponse = req.ctx.LastTimeoutErrorResponse()
+}
+
+// TimeoutErrorWithResponse sends a copy of the specified response, ignoring what the handler writes afterward. It
+// is meant to be used along with Detach, so the handler chain can keep running in background. From then on,
+// Response and ResponseHeaders return the copy so previous middlewares can inspect it safely.
+func (req *RequestContext) TimeoutErrorWithResponse(resp *fasthttp.Response) {
+	req.ctx.TimeoutErrorWithResponse(resp)
+	req.timeoutResponse = req.ctx.LastTimeoutErrorResponse()
+}
+
 func (req *RequestContext) Host() string {
 	if req.isProxyTrusted() {
-		host := req.ctx.Request.Header.PeekBytes(util.HeaderXForwardedHost)
+		host := forwardedHost(&req.ctx.Request.Header)
 		if len(host) > 0 {
-			commaPos := bytes.IndexByte(host, ',')
-			if commaPos >= 0 {
-				return string(host[:commaPos])
-			}
 			return string(host)
 		}
 	}
@@ -196,6 +239,19 @@ func (req *RequestContext) RemoteIP() net.IP {
 	return req.ctx.RemoteIP()
 }
 
+// ProxyProtocolTLVs returns the type-length-value vectors sent in the PROXY protocol v2 header of the
+// connection, if any.
+func (req *RequestContext) ProxyProtocolTLVs() []proxy_protocol.TLV {
+	conn := req.ctx.Conn()
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if ppConn, ok := conn.(*proxy_protocol.Conn); ok {
+		return ppConn.TLVs()
+	}
+	return nil
+}
+
 func (req *RequestContext) Scheme() string {
 	if req.ctx.IsTLS() {
 		return "https"
@@ -223,6 +279,57 @@ func (req *RequestContext) Scheme() string {
 	return scheme
 }
 
+// URLFor builds the path of the named route. See Server.URL for details. If the request is being handled by a
+// mounted server, the mount prefix is included.
+func (req *RequestContext) URLFor(name string, params ...string) (string, error) {
+	path, err := req.srv.URL(name, params...)
+	if err != nil {
+		return "", err
+	}
+	return req.pathPrefix + path, nil
+}
+
+// AbsoluteURLFor builds the full URL of the named route using the scheme and host of the current request.
+func (req *RequestContext) AbsoluteURLFor(name string, params ...string) (string, error) {
+	path, err := req.URLFor(name, params...)
+	if err != nil {
+		return "", err
+	}
+	return req.Scheme() + "://" + req.Host() + path, nil
+}
+
+// Route returns the route that matched the request or nil if none did, for e.g., in not found handlers. The value
+// is set when the server's router runs, so middlewares added with Server.Use must call Next before querying it.
+func (req *RequestContext) Route() *Route {
+	return req.route
+}
+
+// RoutePattern returns the path pattern of the route that matched the request, including the prefix of mounted
+// servers, or an empty string if none did. Use it instead of the path in logs and metrics to avoid high
+// cardinality.
+func (req *RequestContext) RoutePattern() string {
+	return req.routePattern
+}
+
+// Log returns the server's logger with the request id, method, path and remote IP address attached to it.
+func (req *RequestContext) Log() *slog.Logger {
+	if req.logger == nil {
+		req.logger = req.srv.logger.With(
+			slog.String("request_id", req.requestID),
+			slog.String("method", string(req.ctx.Method())),
+			slog.String("path", string(req.ctx.Path())),
+			slog.String("remote_ip", req.RemoteIP().String()),
+		)
+	}
+	return req.logger
+}
+
+// HandleError sends the error using the server's RequestErrorHandler. It is meant for middlewares that need the
+// final response, like loggers, and must not be called more than once per request.
+func (req *RequestContext) HandleError(err error) {
+	req.srv.requestErrorHandler(req, err)
+}
+
 func (req *RequestContext) Next() error {
 	var err error
 
@@ -240,9 +347,11 @@ func (req *RequestContext) Next() error {
 				err = errPathNotHanlded
 			}
 		}
-	} else if req.middlewareIndex <= req.srvMiddlewaresLen+1+req.middlewaresLen {
-		err = req.middlewares[req.middlewareIndex-req.srvMiddlewaresLen-2](req)
-	} else if req.middlewareIndex == req.srvMiddlewaresLen+2+req.middlewaresLen {
+	} else if req.middlewareIndex <= req.srvMiddlewaresLen+1+req.groupMiddlewaresLen {
+		err = req.groupMiddlewares[req.middlewareIndex-req.srvMiddlewaresLen-2](req)
+	} else if req.middlewareIndex <= req.srvMiddlewaresLen+1+req.groupMiddlewaresLen+req.middlewaresLen {
+		err = req.middlewares[req.middlewareIndex-req.srvMiddlewaresLen-req.groupMiddlewaresLen-2](req)
+	} else if req.middlewareIndex == req.srvMiddlewaresLen+2+req.groupMiddlewaresLen+req.middlewaresLen {
 		err = req.handler(req)
 	} else {
 		err = errInvalidCallToNext
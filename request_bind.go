@@ -0,0 +1,516 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// -----------------------------------------------------------------------------
+
+// BindError is returned by RequestContext.Bind and lists every field that cannot be bound or validated.
+type BindError struct {
+	Fields []BindFieldError `json:"fields"`
+}
+
+// BindFieldError contains the details of a field that failed to bind or validate.
+type BindFieldError struct {
+	// Field is the name of the field in the request, for e.g., the query argument name.
+	Field string `json:"field"`
+
+	// Source is where the field was taken from: path, query, header, form or body.
+	Source string `json:"source"`
+
+	// Rule is the validation rule that failed. Empty if the value cannot be converted.
+	Rule string `json:"rule,omitempty"`
+
+	// Message is a human-readable description of the error.
+	Message string `json:"message"`
+}
+
+type bindSource struct {
+	tag  string
+	name string
+}
+
+// -----------------------------------------------------------------------------
+
+var (
+	bindSources = []bindSource{
+		{tag: "path", name: "path"},
+		{tag: "query", name: "query"},
+		{tag: "header", name: "header"},
+		{tag: "form", name: "form"},
+	}
+
+	bindRegexCache = sync.Map{}
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// -----------------------------------------------------------------------------
+
+// Error returns a description of the binding errors
+func (e *BindError) Error() string {
+	sb := strings.Builder{}
+	_, _ = sb.WriteString("invalid request")
+	for idx, f := range e.Fields {
+		if idx == 0 {
+			_, _ = sb.WriteString(": ")
+		} else {
+			_, _ = sb.WriteString("; ")
+		}
+		if len(f.Field) > 0 {
+			_, _ = sb.WriteString(f.Source + " field '" + f.Field + "' " + f.Message)
+		} else {
+			_, _ = sb.WriteString(f.Source + " " + f.Message)
+		}
+	}
+	return sb.String()
+}
+
+func (e *BindError) add(field string, source string, rule string, msg string) {
+	e.Fields = append(e.Fields, BindFieldError{
+		Field:   field,
+		Source:  source,
+		Rule:    rule,
+		Message: msg,
+	})
+}
+
+func (e *BindError) has(field string, source string) bool {
+	for _, f := range e.Fields {
+		if f.Field == field && f.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+// Bind fills the struct pointed by dst with values taken from the request and validates them.
+//
+// If the request has a JSON body, it is decoded into dst first, using the standard `json` tags. Then, fields
+// tagged with `path:"name"`, `query:"name"`, `header:"name"`, or `form:"name"` are set from the route
+// parameters, query arguments, request headers or url-encoded/multipart form values respectively. Strings,
+// booleans, numbers, time.Duration, encoding.TextUnmarshaler implementations (like time.Time in RFC 3339
+// format), pointers to them and slices of them, for multi-valued arguments, are supported.
+//
+// Validation rules are specified in the `validate` tag separated by commas:
+//
+//	required       The value must be present or, for body fields, not be the zero value.
+//	min=N, max=N   Limits the value of numbers or the length of strings and slices.
+//	enum=a|b|c     The value must be one of the listed ones.
+//	regex=EXPR     The string value must match the regular expression. It must be the last rule.
+//
+// If one or more fields cannot be bound or validated, a *BindError listing all of them is returned. This includes
+// the body when it is not a valid JSON document, in which case, the body fields are not validated.
+func (req *RequestContext) Bind(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind destination must be a non-nil pointer to a struct")
+	}
+
+	bindErr := &BindError{}
+	bodyDecoded := true
+
+	// Decode the body if it is JSON
+	body := req.ctx.PostBody()
+	if len(body) > 0 && isJSONContentType(req.ctx.Request.Header.ContentType()) {
+		err := json.Unmarshal(body, dst)
+		if err != nil {
+			var typeErr *json.UnmarshalTypeError
+
+			// The rest of the body is decoded on type errors, else its fields are not validated
+			if errors.As(err, &typeErr) && len(typeErr.Field) > 0 {
+				bindErr.add(typeErr.Field, "body", "", "has an invalid value")
+			} else {
+				bindErr.add("", "body", "", "is not a valid JSON document")
+				bodyDecoded = false
+			}
+		}
+	}
+
+	// Process fields
+	req.bindStruct(rv.Elem(), bodyDecoded, bindErr)
+	if len(bindErr.Fields) > 0 {
+		return bindErr
+	}
+
+	// Done
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (req *RequestContext) bindStruct(v reflect.Value, bodyDecoded bool, bindErr *BindError) {
+	t := v.Type()
+	for idx := 0; idx < t.NumField(); idx++ {
+		sf := t.Field(idx)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(idx)
+
+		// Find the source of the value
+		var source string
+		var key string
+		for _, src := range bindSources {
+			if tag, ok := sf.Tag.Lookup(src.tag); ok && tag != "-" && len(tag) > 0 {
+				source = src.name
+				key = tag
+				break
+			}
+		}
+
+		present := false
+		if len(source) > 0 {
+			values := req.bindValues(source, key)
+			if len(values) > 0 {
+				err := setFieldValues(fv, values)
+				if err != nil {
+					bindErr.add(key, source, "", err.Error())
+					continue
+				}
+				present = true
+			}
+		} else {
+			// Process nested structs
+			if isNestedStruct(sf.Type) {
+				req.bindStruct(fv, bodyDecoded, bindErr)
+				continue
+			}
+
+			source = "body"
+			key = jsonFieldName(sf)
+			if len(key) == 0 || !bodyDecoded || bindErr.has(key, source) {
+				continue
+			}
+			present = !fv.IsZero()
+		}
+
+		validateField(sf.Tag.Get("validate"), fv, present, key, source, bindErr)
+	}
+}
+
+func (req *RequestContext) bindValues(source string, key string) []string {
+	var values []string
+
+	switch source {
+	case "path":
+		if v, ok := req.UserValueAsString([]byte(key)); ok {
+			values = append(values, v)
+		}
+
+	case "query":
+		for _, v := range req.ctx.QueryArgs().PeekMulti(key) {
+			values = append(values, string(v))
+		}
+
+	case "header":
+		for _, v := range req.ctx.Request.Header.PeekAll(key) {
+			values = append(values, string(v))
+		}
+
+	case "form":
+		for _, v := range req.ctx.PostArgs().PeekMulti(key) {
+			values = append(values, string(v))
+		}
+		if len(values) == 0 && bytes.HasPrefix(req.ctx.Request.Header.ContentType(), []byte("multipart/form-data")) {
+			form, err := req.ctx.MultipartForm()
+			if err == nil && form != nil {
+				values = append(values, form.Value[key]...)
+			}
+		}
+	}
+
+	// Done
+	return values
+}
+
+// -----------------------------------------------------------------------------
+
+func setFieldValues(fv reflect.Value, values []string) error {
+	t := fv.Type()
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for idx, value := range values {
+			err := setFieldValue(slice.Index(idx), value)
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFieldValue(fv, values[0])
+}
+
+func setFieldValue(fv reflect.Value, value string) error {
+	if fv.Kind() == reflect.Pointer {
+		elem := reflect.New(fv.Type().Elem())
+		err := setFieldValue(elem.Elem(), value)
+		if err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		err := fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		if err != nil {
+			return errors.New("has an invalid value")
+		}
+		return nil
+	}
+
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("is not a valid duration")
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("is not a valid boolean")
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid integer")
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid unsigned integer")
+		}
+		fv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		fv.SetFloat(f)
+
+	case reflect.Slice:
+		// A byte slice
+		fv.SetBytes([]byte(value))
+
+	default:
+		return errors.New("has an unsupported type")
+	}
+
+	// Done
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func validateField(rules string, fv reflect.Value, present bool, key string, source string, bindErr *BindError) {
+	for len(rules) > 0 {
+		var rule string
+
+		if strings.HasPrefix(rules, "regex=") {
+			rule = rules
+			rules = ""
+		} else if commaPos := strings.IndexByte(rules, ','); commaPos >= 0 {
+			rule = rules[:commaPos]
+			rules = rules[commaPos+1:]
+		} else {
+			rule = rules
+			rules = ""
+		}
+
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if !present {
+				bindErr.add(key, source, name, "is required")
+				return
+			}
+			continue
+		}
+		if !present {
+			continue
+		}
+
+		v := fv
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				break
+			}
+			v = v.Elem()
+		}
+
+		switch name {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				bindErr.add(key, source, name, "has an invalid validation rule")
+				return
+			}
+			size, unit, ok := measureValue(v)
+			if !ok {
+				continue
+			}
+			if name == "min" && size < limit {
+				if len(unit) == 0 {
+					bindErr.add(key, source, name, fmt.Sprintf("must be greater than or equal to %v", arg))
+				} else {
+					bindErr.add(key, source, name, fmt.Sprintf("must have at least %v %v", arg, unit))
+				}
+				return
+			}
+			if name == "max" && size > limit {
+				if len(unit) == 0 {
+					bindErr.add(key, source, name, fmt.Sprintf("must be less than or equal to %v", arg))
+				} else {
+					bindErr.add(key, source, name, fmt.Sprintf("must have at most %v %v", arg, unit))
+				}
+				return
+			}
+
+		case "enum":
+			allowed := strings.Split(arg, "|")
+			if !matchEnum(v, allowed) {
+				bindErr.add(key, source, name, "must be one of: "+strings.Join(allowed, ", "))
+				return
+			}
+
+		case "regex":
+			re, err := compileBindRegex(arg)
+			if err != nil {
+				bindErr.add(key, source, name, "has an invalid validation rule")
+				return
+			}
+			if !matchRegex(v, re) {
+				bindErr.add(key, source, name, "has an invalid format")
+				return
+			}
+
+		default:
+			bindErr.add(key, source, name, "has an unknown validation rule")
+			return
+		}
+	}
+}
+
+// measureValue returns the numeric value of numbers or the length of strings, slices and maps along with the
+// unit of the latter.
+func measureValue(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "elements", true
+	}
+	return 0, "", false
+}
+
+func matchEnum(v reflect.Value, allowed []string) bool {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		for idx := 0; idx < v.Len(); idx++ {
+			if !matchEnum(v.Index(idx), allowed) {
+				return false
+			}
+		}
+		return true
+	}
+
+	s := fmt.Sprint(v.Interface())
+	for _, a := range allowed {
+		if s == a {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRegex(v reflect.Value, re *regexp.Regexp) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return re.MatchString(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			for idx := 0; idx < v.Len(); idx++ {
+				if !re.MatchString(v.Index(idx).String()) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return re.MatchString(fmt.Sprint(v.Interface()))
+}
+
+func compileBindRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := bindRegexCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	bindRegexCache.Store(expr, re)
+	return re, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func jsonFieldName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if len(name) == 0 {
+		name = sf.Name
+	}
+	return name
+}
+
+func isJSONContentType(contentType []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(string(contentType))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
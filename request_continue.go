@@ -0,0 +1,72 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// ContinueHandler defines a function that decides if the body of a request sent with the "Expect: 100-continue"
+// header must be read. It runs on a copy of the request headers and has the connection details, like the remote
+// address, so trusted-proxy helpers like RequestContext.RemoteIP behave like in handlers. Return nil to accept the
+// request or an error to reject it without reading the body.
+//
+// Rejected requests always get an empty 417 response, which is the only one the underlying fasthttp server can
+// send at this stage, and the connection is closed. The returned error is not sent to the client.
+type ContinueHandler func(req *RequestContext) error
+
+// -----------------------------------------------------------------------------
+
+var continueCtxPool = sync.Pool{
+	New: func() any {
+		return &fasthttp.RequestCtx{}
+	},
+}
+
+// -----------------------------------------------------------------------------
+
+// onContinue runs the continue hooks of the server and the route that will handle the request. Bodies that
+// exceed the maximum size are rejected by fasthttp with a 413 status before they are read.
+func (srv *Server) onContinue(header *fasthttp.RequestHeader) bool {
+	// Run the hooks on a copy of the header so they do not touch the request being read
+	ctx := continueCtxPool.Get().(*fasthttp.RequestCtx)
+	defer func() {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.ResetUserValues()
+		continueCtxPool.Put(ctx)
+	}()
+	ctx.Init2(srv.conns.current(), srv.fastserver.Logger, true)
+	header.CopyTo(&ctx.Request.Header)
+
+	req, freeReq := srv.requestCtxPool.newRequestContext(ctx, srv)
+	defer freeReq()
+
+	// Done
+	return srv.runContinueHandlers(req) == nil
+}
+
+func (srv *Server) runContinueHandlers(req *RequestContext) error {
+	if srv.continueHandler != nil {
+		err := srv.continueHandler(req)
+		if err != nil {
+			return err
+		}
+	}
+
+	rt := srv.selectRouteTable(req)
+	hr := rt.findHeaderRoute(req.ctx.Method(), req.ctx.Request.Header.RequestURI(), req.ctx)
+	if hr != nil && hr.continueHandler != nil {
+		err := hr.continueHandler(req)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Done
+	return nil
+}
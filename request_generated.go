@@ -334,21 +334,6 @@ func (req *RequestContext) Time() (out1 time.Time) {
 	return
 }
 
-func (req *RequestContext) TimeoutError(in1 string) {
-	req.ctx.TimeoutError(in1)
-	return
-}
-
-func (req *RequestContext) TimeoutErrorWithCode(in1 string, in2 int) {
-	req.ctx.TimeoutErrorWithCode(in1, in2)
-	return
-}
-
-func (req *RequestContext) TimeoutErrorWithResponse(in1 *fasthttp.Response) {
-	req.ctx.TimeoutErrorWithResponse(in1)
-	return
-}
-
 func (req *RequestContext) URI() (out1 *fasthttp.URI) {
 	out1 = req.ctx.URI()
 	return
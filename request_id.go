@@ -0,0 +1,37 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"context"
+)
+
+// -----------------------------------------------------------------------------
+
+type requestIDKey struct{}
+
+// -----------------------------------------------------------------------------
+
+// RequestID returns the id assigned to the request with SetRequestID, for e.g., by the request id middleware.
+func (req *RequestContext) RequestID() string {
+	return req.requestID
+}
+
+// SetRequestID assigns an id to the request. It is added to the user context, the logger returned by Log and the
+// problem details sent by SendError. If header is not empty, the id is also sent in that response header, even if
+// the response is reset by Error.
+func (req *RequestContext) SetRequestID(id string, header string) {
+	req.requestID = id
+	req.requestIDHeader = header
+	req.logger = nil
+	req.userCtx = context.WithValue(req.UserContext(), requestIDKey{}, id)
+	if len(header) > 0 {
+		req.ctx.Response.Header.Set(header, id)
+	}
+}
+
+// RequestIDFromContext returns the request id stored in a user context or an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
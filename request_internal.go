@@ -3,9 +3,11 @@
 package go_webserver
 
 import (
+	"bytes"
 	"net"
 	"net/netip"
 
+	"github.com/mxmauro/go-webserver/v2/util"
 	"github.com/valyala/fasthttp"
 )
 
@@ -13,6 +15,7 @@ import (
 
 const (
 	reqContextLinkKey = "\xFF\xFF**reqContextLinkKey"
+	headerRouteKey    = "\xFF\xFF**headerRouteKey"
 )
 
 // -----------------------------------------------------------------------------
@@ -35,14 +38,34 @@ func (req *RequestContext) isProxyTrusted() bool {
 	return req.tp.IsIpTrusted(req.ctx.RemoteIP())
 }
 
-func (req *RequestContext) setHandlerParams(h HandlerFunc, middlewares []HandlerFunc) {
+func (req *RequestContext) setHandlerParams(r *Route, h HandlerFunc, groupMiddlewares []HandlerFunc,
+	middlewares []HandlerFunc,
+) {
+	req.route = r
+	if r != nil {
+		req.routePattern = req.pathPrefix + r.path
+	} else {
+		req.routePattern = ""
+	}
 	req.handler = h
+	req.groupMiddlewares = groupMiddlewares
+	req.groupMiddlewaresLen = len(groupMiddlewares)
 	req.middlewares = middlewares
 	req.middlewaresLen = len(middlewares)
 }
 
 // -----------------------------------------------------------------------------
 
+// forwardedHost returns the first host of the X-Forwarded-Host header, if any.
+func forwardedHost(header *fasthttp.RequestHeader) []byte {
+	host := header.PeekBytes(util.HeaderXForwardedHost)
+	commaPos := bytes.IndexByte(host, ',')
+	if commaPos >= 0 {
+		return host[:commaPos]
+	}
+	return host
+}
+
 func getFirstIpAddress(header []byte) net.IP {
 	ofs := 0
 	l := len(header)
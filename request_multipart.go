@@ -0,0 +1,270 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// MultipartReaderOptions sets optional parameters for RequestContext.MultipartReader.
+type MultipartReaderOptions struct {
+	// MaxParts is the maximum number of parts the body can contain. Defaults to 1000.
+	MaxParts int
+
+	// MaxPartSize is the maximum size, in bytes, of the content of a part. Zero means no limit.
+	MaxPartSize int64
+
+	// MaxTotalSize is the maximum size, in bytes, of the whole body. Zero means no limit.
+	MaxTotalSize int64
+
+	// TempDir is the directory where parts are spooled by MultipartPart.Spool. Defaults to the system's temporary
+	// directory.
+	TempDir string
+
+	// DiskQuota is the maximum number of bytes all the spooled files of a body can use. Zero means no limit.
+	DiskQuota int64
+}
+
+// MultipartReader iterates over the parts of a multipart request body as they arrive, without buffering them.
+type MultipartReader struct {
+	r         *multipart.Reader
+	opts      MultipartReaderOptions
+	parts     int
+	current   *MultipartPart
+	diskUsage int64
+	spooled   []*SpooledFile
+}
+
+// MultipartPart is a part of a multipart body. Its content is only available until the next call to
+// MultipartReader.NextPart.
+type MultipartPart struct {
+	mr   *MultipartReader
+	p    *multipart.Part
+	size int64
+}
+
+// SpooledFile contains the details of a part saved to disk.
+type SpooledFile struct {
+	FormName    string
+	FileName    string
+	ContentType string
+	Path        string
+	Size        int64
+}
+
+type multipartBodyReader struct {
+	r       io.Reader
+	read    int64
+	maxSize int64
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultMultipartMaxParts = 1000
+)
+
+var (
+	ErrMultipartPartTooLarge      = errors.New("multipart part too large")
+	ErrMultipartTooLarge          = errors.New("multipart body too large")
+	ErrMultipartTooManyParts      = errors.New("too many multipart parts")
+	ErrMultipartDiskQuotaExceeded = errors.New("multipart disk quota exceeded")
+)
+
+// -----------------------------------------------------------------------------
+
+func init() {
+	RegisterErrorStatus(ErrMultipartPartTooLarge, http.StatusRequestEntityTooLarge)
+	RegisterErrorStatus(ErrMultipartTooLarge, http.StatusRequestEntityTooLarge)
+	RegisterErrorStatus(ErrMultipartTooManyParts, http.StatusRequestEntityTooLarge)
+	RegisterErrorStatus(ErrMultipartDiskQuotaExceeded, http.StatusRequestEntityTooLarge)
+}
+
+// MultipartReader returns a reader to iterate over the parts of a multipart request body. If the server has
+// StreamRequestBody enabled, the parts are read from the connection as the handler consumes them, so set
+// DisablePreParseMultipartForm too in order to prevent the server from parsing the form before calling the
+// handler.
+//
+// The limit errors are mapped to a 413 status by ToHTTPError. Call Close once done to remove the spooled files.
+func (req *RequestContext) MultipartReader(opts ...MultipartReaderOptions) (*MultipartReader, error) {
+	var o MultipartReaderOptions
+
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxParts <= 0 {
+		o.MaxParts = defaultMultipartMaxParts
+	}
+
+	mediaType, params, err := mime.ParseMediaType(string(req.ctx.Request.Header.ContentType()))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, NewHTTPError(http.StatusUnsupportedMediaType, "multipart content expected")
+	}
+	boundary := params["boundary"]
+	if len(boundary) == 0 {
+		return nil, NewHTTPError(http.StatusBadRequest, "multipart boundary not specified")
+	}
+
+	body := req.ctx.RequestBodyStream()
+	if body == nil {
+		postBody := req.ctx.PostBody()
+		if len(postBody) == 0 && req.ctx.Request.Header.ContentLength() > 0 {
+			return nil, errors.New("multipart form already parsed, set DisablePreParseMultipartForm")
+		}
+		body = bytes.NewReader(postBody)
+	}
+
+	mr := &MultipartReader{
+		r: multipart.NewReader(&multipartBodyReader{
+			r:       body,
+			maxSize: o.MaxTotalSize,
+		}, boundary),
+		opts: o,
+	}
+
+	// Done
+	return mr, nil
+}
+
+// NextPart returns the next part of the body or io.EOF if there are no more parts.
+func (mr *MultipartReader) NextPart() (*MultipartPart, error) {
+	if mr.current != nil {
+		_ = mr.current.p.Close()
+		mr.current = nil
+	}
+
+	if mr.parts >= mr.opts.MaxParts {
+		// Check if the body ends here
+		_, err := mr.r.NextPart()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, ErrMultipartTooManyParts
+	}
+
+	p, err := mr.r.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	mr.parts += 1
+
+	mr.current = &MultipartPart{
+		mr: mr,
+		p:  p,
+	}
+
+	// Done
+	return mr.current, nil
+}
+
+// SpooledFiles returns the parts saved to disk.
+func (mr *MultipartReader) SpooledFiles() []*SpooledFile {
+	return mr.spooled
+}
+
+// Close removes the spooled files. Move the ones to keep to another location before calling it.
+func (mr *MultipartReader) Close() {
+	if mr.current != nil {
+		_ = mr.current.p.Close()
+		mr.current = nil
+	}
+	for _, f := range mr.spooled {
+		_ = os.Remove(f.Path)
+	}
+	mr.spooled = nil
+}
+
+// FormName returns the name of the form field of the part.
+func (p *MultipartPart) FormName() string {
+	return p.p.FormName()
+}
+
+// FileName returns the file name of the part, if any.
+func (p *MultipartPart) FileName() string {
+	return p.p.FileName()
+}
+
+// Header returns the headers of the part.
+func (p *MultipartPart) Header() textproto.MIMEHeader {
+	return p.p.Header
+}
+
+// ContentType returns the content type of the part.
+func (p *MultipartPart) ContentType() string {
+	return p.p.Header.Get("Content-Type")
+}
+
+// Read reads the content of the part.
+func (p *MultipartPart) Read(b []byte) (int, error) {
+	n, err := p.p.Read(b)
+	p.size += int64(n)
+	if p.mr.opts.MaxPartSize > 0 && p.size > p.mr.opts.MaxPartSize {
+		n -= int(p.size - p.mr.opts.MaxPartSize)
+		p.size = p.mr.opts.MaxPartSize
+		return n, ErrMultipartPartTooLarge
+	}
+	return n, err
+}
+
+// Spool saves the remaining content of the part into a file in the temporary directory.
+func (p *MultipartPart) Spool() (*SpooledFile, error) {
+	mr := p.mr
+
+	f, err := os.CreateTemp(mr.opts.TempDir, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file [err=%w]", err)
+	}
+
+	var src io.Reader = p
+	if mr.opts.DiskQuota > 0 {
+		// Read one byte more than the available space to detect if the quota is exceeded
+		src = io.LimitReader(p, mr.opts.DiskQuota-mr.diskUsage+1)
+	}
+	size, err := io.Copy(f, src)
+	if err == nil && mr.opts.DiskQuota > 0 && mr.diskUsage+size > mr.opts.DiskQuota {
+		err = ErrMultipartDiskQuotaExceeded
+	}
+	closeErr := f.Close()
+	if err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to write temporary file [err=%w]", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	mr.diskUsage += size
+
+	sf := &SpooledFile{
+		FormName:    p.FormName(),
+		FileName:    p.FileName(),
+		ContentType: p.ContentType(),
+		Path:        f.Name(),
+		Size:        size,
+	}
+	mr.spooled = append(mr.spooled, sf)
+
+	// Done
+	return sf, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (r *multipartBodyReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.read += int64(n)
+	if r.maxSize > 0 && r.read > r.maxSize {
+		return 0, ErrMultipartTooLarge
+	}
+	return n, err
+}
@@ -28,26 +28,93 @@ func newRequestContextPool() *RequestContextPool {
 
 func (rcp *RequestContextPool) newRequestContext(ctx *fasthttp.RequestCtx, srv *Server) (*RequestContext, func()) {
 	req, _ := rcp.pool.Get().(*RequestContext)
+	req.pool = rcp
 	req.ctx = ctx
+	req.srv = srv
 	req.tp = srv.trustedProxy
-	req.srvRouterHandler = srv.router.Handler
-	req.srvMiddlewares = srv.middlewares
-	req.srvMiddlewaresLen = len(srv.middlewares)
+	rt := srv.selectRouteTable(req)
+	req.srvRouterHandler = rt.router.Load().Handler
+	req.srvMiddlewares = *rt.chain.Load()
+	req.srvMiddlewaresLen = len(req.srvMiddlewares)
 	ctx.SetUserValue(reqContextLinkKey, req)
 
-	return req, func() {
-		ctx.RemoveUserValue(reqContextLinkKey)
-		req.ctx = nil
-		req.tp = nil
-		req.userCtx = nil
-		req.handler = nil
-		req.srvRouterHandler = nil
-		// req.middlewareIndex = 0
-		req.srvMiddlewares = nil
-		// req.srvMiddlewaresLen = 0
-		req.middlewares = nil
-		// req.middlewaresLen = 0
-
-		rcp.pool.Put(req)
+	return req, req.release
+}
+
+// -----------------------------------------------------------------------------
+
+// Detach creates a copy of the request context that can be used to continue processing the request from another
+// goroutine, for e.g., to keep running the handler chain after a response was sent with TimeoutErrorWithResponse.
+// The copy takes over the underlying request so, until it ends, the original request context must not be used to
+// access the request.
+//
+// If the original request context waits for the copy, call Attach to end it. Otherwise, call the returned function
+// once the copy is no longer used.
+func (req *RequestContext) Detach() (*RequestContext, func()) {
+	// The URI is parsed lazily, so parse it now to let the original request context read it after a timeout
+	_ = req.ctx.URI()
+
+	dup, _ := req.pool.pool.Get().(*RequestContext)
+	*dup = *req
+	dup.detached = false
+	req.detached = true
+	req.ctx.SetUserValue(reqContextLinkKey, dup)
+
+	once := sync.Once{}
+	return dup, func() {
+		once.Do(func() {
+			// The copy starts in the middle of the chain so its index does not go back to zero
+			dup.middlewareIndex = 0
+			dup.release()
+		})
 	}
 }
+
+// Attach ends a copy created with Detach, once its handler chain returned, and takes over the request again. The
+// state set by the chain in the copy, like the matched route, the request id or the user context, is kept.
+func (req *RequestContext) Attach(dup *RequestContext) {
+	req.route = dup.route
+	req.routePattern = dup.routePattern
+	req.requestID = dup.requestID
+	req.requestIDHeader = dup.requestIDHeader
+	req.logger = dup.logger
+	req.userCtx = dup.userCtx
+	req.detached = false
+	req.ctx.SetUserValue(reqContextLinkKey, req)
+
+	// Release the copy without unlinking the request
+	dup.detached = true
+	dup.middlewareIndex = 0
+	dup.release()
+}
+
+func (req *RequestContext) release() {
+	rcp := req.pool
+	if !req.detached {
+		req.ctx.RemoveUserValue(reqContextLinkKey)
+	}
+	req.detached = false
+	req.ctx = nil
+	req.srv = nil
+	req.pool = nil
+	req.tp = nil
+	req.userCtx = nil
+	req.pathPrefix = ""
+	req.route = nil
+	req.routePattern = ""
+	req.logger = nil
+	req.timeoutResponse = nil
+	req.requestID = ""
+	req.requestIDHeader = ""
+	req.handler = nil
+	req.srvRouterHandler = nil
+	// req.middlewareIndex = 0
+	req.srvMiddlewares = nil
+	// req.srvMiddlewaresLen = 0
+	req.groupMiddlewares = nil
+	// req.groupMiddlewaresLen = 0
+	req.middlewares = nil
+	// req.middlewaresLen = 0
+
+	rcp.pool.Put(req)
+}
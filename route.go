@@ -0,0 +1,271 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// Route represents an endpoint added to the server. It is returned by the route registration methods and can be
+// used to attach extra settings to it.
+type Route struct {
+	srv              *Server
+	rt               *routeTable
+	method           string
+	path             string
+	name             string
+	description      string
+	tags             []string
+	handler          HandlerFunc
+	groupMiddlewares []HandlerFunc
+	middlewares      []HandlerFunc
+	fastHandler      fasthttp.RequestHandler
+	maxBodySize      int
+	continueHandler  ContinueHandler
+}
+
+// -----------------------------------------------------------------------------
+
+// Name assigns a name to the route so URLs pointing to it can be generated with Server.URL and
+// RequestContext.URLFor. It panics if the name is empty or already assigned to another route.
+func (r *Route) Name(name string) *Route {
+	if len(name) == 0 {
+		panic("route name cannot be empty")
+	}
+
+	r.srv.routesMtx.Lock()
+	defer r.srv.routesMtx.Unlock()
+
+	if other, ok := r.srv.namedRoutes[name]; ok && other != r {
+		panic("route name '" + name + "' is already in use")
+	}
+	if len(r.name) > 0 {
+		delete(r.srv.namedRoutes, r.name)
+	}
+	r.name = name
+	r.srv.namedRoutes[name] = r
+	return r
+}
+
+// Description sets an optional description of the route that is shown in the route table
+func (r *Route) Description(description string) *Route {
+	r.srv.routesMtx.Lock()
+	defer r.srv.routesMtx.Unlock()
+
+	r.description = description
+	return r
+}
+
+// Tags adds optional tags to the route that are shown in the route table
+func (r *Route) Tags(tags ...string) *Route {
+	r.srv.routesMtx.Lock()
+	defer r.srv.routesMtx.Unlock()
+
+	r.tags = append(r.tags, tags...)
+	return r
+}
+
+// MaxRequestBodySize overrides the server's maximum request body size for this route. The route is matched using
+// the request's method, path and Host header before the body is read. If StreamRequestBody is enabled, bodies
+// bigger than the size are streamed to the handler instead of being rejected.
+//
+// NOTE: The override is not applied to routes of mounted servers.
+func (r *Route) MaxRequestBodySize(size int) *Route {
+	if size <= 0 {
+		panic("invalid maximum request body size")
+	}
+
+	r.srv.routesMtx.Lock()
+	defer r.srv.routesMtx.Unlock()
+
+	r.maxBodySize = size
+	r.rt.updateHeaderRoutes()
+	return r
+}
+
+// ContinueHandler sets a hook to decide if the body of requests sent with the "Expect: 100-continue" header must
+// be read. It runs after the server's one, if any. See Options.ContinueHandler for details.
+//
+// NOTE: The hook is not applied to routes of mounted servers.
+func (r *Route) ContinueHandler(handler ContinueHandler) *Route {
+	r.srv.routesMtx.Lock()
+	defer r.srv.routesMtx.Unlock()
+
+	r.continueHandler = handler
+	r.rt.updateHeaderRoutes()
+	return r
+}
+
+// Remove removes the route from the server. It can be safely called while the server is running. In-flight
+// requests already dispatched to the route are not affected. Returns false if the route was already removed.
+func (r *Route) Remove() bool {
+	return r.rt.removeRoute(r)
+}
+
+// Method returns the HTTP method of the route
+func (r *Route) Method() string {
+	return r.method
+}
+
+// Path returns the path pattern of the route
+func (r *Route) Path() string {
+	return r.path
+}
+
+func (r *Route) hostPattern() string {
+	if r.rt.host == nil {
+		return ""
+	}
+	return r.rt.host.pattern
+}
+
+// -----------------------------------------------------------------------------
+
+// RemoveRoute removes the route registered with the specified method and path pattern. It can be safely called
+// while the server is running. Returns false if no route matches.
+func (srv *Server) RemoveRoute(method string, path string) bool {
+	r := srv.defaultRoutes.findRoute(method, path)
+	if r == nil {
+		return false
+	}
+	return r.Remove()
+}
+
+// URL builds the path of the named route replacing its parameters with the provided values. Parameters are
+// specified as key/value pairs, for e.g.: srv.URL("user", "id", "10"). Optional and catch-all parameters can
+// be omitted. Pairs that do not match any route parameter are appended as query arguments.
+func (srv *Server) URL(name string, params ...string) (string, error) {
+	srv.routesMtx.RLock()
+	r, ok := srv.namedRoutes[name]
+	srv.routesMtx.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("route '%v' not found", name)
+	}
+	if len(params)%2 != 0 {
+		return "", errors.New("route parameters must be specified as key/value pairs")
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for idx := 0; idx < len(params); idx += 2 {
+		values[params[idx]] = params[idx+1]
+	}
+
+	path, err := buildRoutePath(r.path, values)
+	if err != nil {
+		return "", fmt.Errorf("unable to build url for route '%v' [err=%w]", name, err)
+	}
+
+	// Add the remaining parameters to the query string
+	if len(values) > 0 {
+		query := url.Values{}
+		for idx := 0; idx < len(params); idx += 2 {
+			if _, ok = values[params[idx]]; ok {
+				query.Add(params[idx], params[idx+1])
+			}
+		}
+		path += "?" + query.Encode()
+	}
+
+	// Done
+	return path, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// buildRoutePath replaces the parameters of a fasthttp/router path pattern with the given values. Used values are
+// removed from the map.
+func buildRoutePath(pattern string, values map[string]string) (string, error) {
+	buf := make([]byte, 0, len(pattern)+32)
+
+	patternLen := len(pattern)
+	for ofs := 0; ofs < patternLen; {
+		if pattern[ofs] != '{' {
+			buf = append(buf, pattern[ofs])
+			ofs += 1
+			continue
+		}
+
+		// Find the closing brace taking into account regular expressions containing them
+		endOfs := -1
+		depth := 0
+		for idx := ofs; idx < patternLen; idx++ {
+			if pattern[idx] == '{' {
+				depth += 1
+			} else if pattern[idx] == '}' {
+				depth -= 1
+				if depth == 0 {
+					endOfs = idx
+					break
+				}
+			}
+		}
+		if endOfs < 0 {
+			return "", errors.New("invalid route pattern")
+		}
+
+		// Parse parameter
+		name := pattern[ofs+1 : endOfs]
+		constraint := ""
+		if colonPos := strings.IndexByte(name, ':'); colonPos >= 0 {
+			constraint = name[colonPos+1:]
+			name = name[:colonPos]
+		}
+		optional := strings.HasSuffix(name, "?")
+		if optional {
+			name = name[:len(name)-1]
+		}
+
+		ofs = endOfs + 1
+
+		value, ok := values[name]
+		if ok {
+			delete(values, name)
+		}
+		if !ok || (optional && len(value) == 0) {
+			if optional || constraint == "*" {
+				// Remove the slash preceding an omitted optional segment
+				if optional && len(buf) > 1 && buf[len(buf)-1] == '/' && (ofs == patternLen || pattern[ofs] == '/') {
+					buf = buf[:len(buf)-1]
+				}
+				continue
+			}
+			return "", fmt.Errorf("missing route parameter '%v'", name)
+		}
+
+		switch constraint {
+		case "*":
+			fragments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for idx := range fragments {
+				fragments[idx] = url.PathEscape(fragments[idx])
+			}
+			buf = append(buf, strings.Join(fragments, "/")...)
+
+		case "":
+			if len(value) == 0 {
+				return "", fmt.Errorf("empty route parameter '%v'", name)
+			}
+			buf = append(buf, url.PathEscape(value)...)
+
+		default:
+			re, err := regexp.Compile("^(?:" + constraint + ")$")
+			if err != nil {
+				return "", fmt.Errorf("invalid constraint for route parameter '%v' [err=%w]", name, err)
+			}
+			if !re.MatchString(value) {
+				return "", fmt.Errorf("route parameter '%v' does not match its constraint", name)
+			}
+			buf = append(buf, url.PathEscape(value)...)
+		}
+	}
+
+	// Done
+	return string(buf), nil
+}
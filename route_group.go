@@ -0,0 +1,134 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// RouteGroup is a set of routes that share a common path prefix and a chain of middlewares.
+type RouteGroup struct {
+	rt          *routeTable
+	prefix      string
+	middlewares []HandlerFunc
+}
+
+// -----------------------------------------------------------------------------
+
+// Group creates a new route group. The group's middlewares are executed after the server-wide ones and before
+// the route's own middlewares.
+func (srv *Server) Group(prefix string, middlewares ...HandlerFunc) *RouteGroup {
+	return &RouteGroup{
+		rt:          &srv.defaultRoutes,
+		prefix:      joinGroupPrefix("", prefix),
+		middlewares: append(make([]HandlerFunc, 0, len(middlewares)), middlewares...),
+	}
+}
+
+// Group creates a nested route group. The nested group inherits the prefix and the middlewares of its parent.
+func (g *RouteGroup) Group(prefix string, middlewares ...HandlerFunc) *RouteGroup {
+	m := make([]HandlerFunc, 0, len(g.middlewares)+len(middlewares))
+	m = append(m, g.middlewares...)
+	m = append(m, middlewares...)
+
+	return &RouteGroup{
+		rt:          g.rt,
+		prefix:      joinGroupPrefix(g.prefix, prefix),
+		middlewares: m,
+	}
+}
+
+// Prefix returns the path prefix of the group
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// GET adds a GET handler for the specified route
+func (g *RouteGroup) GET(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("GET", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// HEAD adds a HEAD handler for the specified route
+func (g *RouteGroup) HEAD(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("HEAD", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// OPTIONS adds a OPTIONS handler for the specified route
+func (g *RouteGroup) OPTIONS(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("OPTIONS", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// POST adds a POST handler for the specified route
+func (g *RouteGroup) POST(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("POST", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// PUT adds a PUT handler for the specified route
+func (g *RouteGroup) PUT(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("PUT", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// PATCH adds a PATCH handler for the specified route
+func (g *RouteGroup) PATCH(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("PATCH", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// DELETE adds a DELETE handler for the specified route
+func (g *RouteGroup) DELETE(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute("DELETE", joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// CustomMethod adds a custom method handler for the specified route
+func (g *RouteGroup) CustomMethod(method string, path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return g.rt.addRoute(method, joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// ServeFiles adds custom filesystem handler for the specified route
+func (g *RouteGroup) ServeFiles(path string, opts ServerFilesOptions, middlewares ...HandlerFunc) error {
+	return g.rt.serveFiles(joinRoutePath(g.prefix, path), opts, g.middlewares, middlewares)
+}
+
+// ServeDebugProfiles adds the GO runtime profile handlers to the group
+func (g *RouteGroup) ServeDebugProfiles(basePath string, middlewares ...HandlerFunc) {
+	g.rt.serveDebugProfiles(joinRoutePath(g.prefix, basePath), g.middlewares, middlewares)
+}
+
+// ServeRouteTable adds a handler that lists the registered routes. See Server.ServeRouteTable for details.
+func (g *RouteGroup) ServeRouteTable(path string, middlewares ...HandlerFunc) *Route {
+	return g.rt.serveRouteTable(joinRoutePath(g.prefix, path), g.middlewares, middlewares)
+}
+
+// WebSocket adds a WebSocket endpoint to the group. See Server.WebSocket for details.
+func (g *RouteGroup) WebSocket(path string, handler WebSocketHandler, middlewares ...HandlerFunc) *Route {
+	return g.rt.webSocket(joinRoutePath(g.prefix, path), handler, g.middlewares, middlewares)
+}
+
+// -----------------------------------------------------------------------------
+
+// joinRoutePath concatenates a group prefix and a route path.
+func joinRoutePath(prefix string, path string) string {
+	if len(path) > 0 && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if len(prefix) > 0 && prefix != "/" {
+		if path == "/" || len(path) == 0 {
+			return prefix
+		}
+		return prefix + path
+	}
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
+
+// joinGroupPrefix concatenates two group prefixes. The result never ends with a slash, except the root one.
+func joinGroupPrefix(prefix string, path string) string {
+	path = joinRoutePath(prefix, path)
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		path = path[:len(path)-1]
+	}
+	return path
+}
@@ -0,0 +1,185 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// RouteInfo contains the details of a registered route.
+type RouteInfo struct {
+	Host            string   `json:"host,omitempty"`
+	Method          string   `json:"method"`
+	Path            string   `json:"path"`
+	Name            string   `json:"name,omitempty"`
+	Handler         string   `json:"handler"`
+	MiddlewareCount int      `json:"middlewareCount"`
+	Middlewares     []string `json:"middlewares"`
+	Description     string   `json:"description,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// Routes returns the list of registered routes, including the virtual hosts' ones, sorted by host, path and
+// method. The listed middlewares are the ones attached to the route and its groups. Server-wide middlewares added
+// with Use are not included.
+func (srv *Server) Routes() []RouteInfo {
+	srv.routesMtx.RLock()
+	defer srv.routesMtx.RUnlock()
+
+	allRoutes := append([]*Route(nil), srv.defaultRoutes.routes...)
+	for _, vh := range srv.virtualHosts {
+		allRoutes = append(allRoutes, vh.rt.routes...)
+	}
+
+	routes := make([]RouteInfo, 0, len(allRoutes))
+	for _, r := range allRoutes {
+		info := RouteInfo{
+			Host:            r.hostPattern(),
+			Method:          r.method,
+			Path:            r.path,
+			Name:            r.name,
+			Handler:         funcName(r.handler),
+			MiddlewareCount: len(r.groupMiddlewares) + len(r.middlewares),
+			Middlewares:     make([]string, 0, len(r.groupMiddlewares)+len(r.middlewares)),
+			Description:     r.description,
+			Tags:            append([]string(nil), r.tags...),
+		}
+		for _, m := range r.groupMiddlewares {
+			info.Middlewares = append(info.Middlewares, funcName(m))
+		}
+		for _, m := range r.middlewares {
+			info.Middlewares = append(info.Middlewares, funcName(m))
+		}
+		routes = append(routes, info)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	// Done
+	return routes
+}
+
+// ServeRouteTable adds a handler that lists the registered routes. The output is a JSON array if the client
+// accepts 'application/json' or the 'format=json' query argument is present, else an HTML page is returned.
+func (srv *Server) ServeRouteTable(path string, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.serveRouteTable(path, nil, middlewares)
+}
+
+func (rt *routeTable) serveRouteTable(path string, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc) *Route {
+	return rt.addRoute("GET", path, func(req *RequestContext) error {
+		return rt.srv.onRouteTable(req)
+	}, groupMiddlewares, middlewares)
+}
+
+func (srv *Server) onRouteTable(req *RequestContext) error {
+	var b bytes.Buffer
+
+	routes := srv.Routes()
+
+	if string(req.QueryArgs().Peek("format")) == "json" ||
+		strings.Contains(req.RequestHeader("Accept"), "application/json") {
+		req.WriteJSON(routes)
+		return nil
+	}
+
+	req.SetResponseHeader("X-Content-Type-Options", "nosniff")
+	req.SetResponseHeader("Content-Type", "text/html; charset=utf-8")
+
+	// Write index header
+	_, _ = b.WriteString(`<!doctype html>
+<html>
+<head>
+<title>Routes</title>
+<style>
+body {
+	font-family: monospace;
+}
+table {
+	border-collapse:collapse;
+}
+td {
+	padding: 2px 8px;
+	vertical-align: top;
+}
+td.header {
+	border-bottom: 1px solid #000;
+}
+</style>
+</head>
+<body>
+<table>
+	<thead>
+		<td class='header'>Host</td>
+		<td class='header'>Method</td>
+		<td class='header'>Path</td>
+		<td class='header'>Name</td>
+		<td class='header'>Handler</td>
+		<td class='header'>Middlewares</td>
+		<td class='header'>Description</td>
+		<td class='header'>Tags</td>
+	</thead>
+	<tbody>
+`)
+
+	// Write each route on main table
+	for _, r := range routes {
+		_, _ = fmt.Fprintf(&b, `		<tr>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+			<td>%s</td>
+		</tr>
+`, html.EscapeString(r.Host), html.EscapeString(r.Method), html.EscapeString(r.Path), html.EscapeString(r.Name),
+			html.EscapeString(r.Handler), html.EscapeString(strings.Join(r.Middlewares, ", ")),
+			html.EscapeString(r.Description), html.EscapeString(strings.Join(r.Tags, ", ")))
+	}
+
+	// Close table and html page
+	_, _ = b.WriteString(`	</tbody>
+</table>
+</body>
+</html>
+`)
+
+	// Write response
+	_, _ = req.Write(b.Bytes())
+	req.Success()
+
+	// Done
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+func funcName(fn HandlerFunc) string {
+	if fn == nil {
+		return ""
+	}
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return f.Name()
+}
@@ -0,0 +1,230 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"sync/atomic"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// routeTable holds the routes, middlewares and fallback handlers of the server or a virtual host. Changes are
+// protected by the server's routesMtx and, once the server is running, published by swapping immutable
+// router and middleware snapshots.
+type routeTable struct {
+	srv                     *Server
+	host                    *VirtualHost
+	router                  atomic.Pointer[router.Router]
+	routes                  []*Route
+	middlewares             []HandlerFunc
+	chain                   atomic.Pointer[[]HandlerFunc]
+	notFoundHandler         fasthttp.RequestHandler
+	methodNotAllowedHandler fasthttp.RequestHandler
+	headerRoutes            atomic.Pointer[router.Router]
+}
+
+// headerRoute holds the settings of a route that are applied once the request headers are received.
+type headerRoute struct {
+	maxBodySize     int
+	continueHandler ContinueHandler
+}
+
+// -----------------------------------------------------------------------------
+
+func (rt *routeTable) init(srv *Server, host *VirtualHost, notFoundHandler fasthttp.RequestHandler,
+	methodNotAllowedHandler fasthttp.RequestHandler,
+) {
+	rt.srv = srv
+	rt.host = host
+	rt.notFoundHandler = notFoundHandler
+	rt.methodNotAllowedHandler = methodNotAllowedHandler
+	rt.router.Store(rt.newRouter())
+	rt.updateChain()
+}
+
+func (rt *routeTable) addRoute(method string, path string, h HandlerFunc, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc) *Route {
+	srv := rt.srv
+
+	r := &Route{
+		srv:              srv,
+		rt:               rt,
+		method:           method,
+		path:             path,
+		handler:          h,
+		groupMiddlewares: groupMiddlewares,
+		middlewares:      middlewares,
+	}
+	r.fastHandler = srv.createEndpointHandler(r, h, groupMiddlewares, middlewares)
+
+	srv.routesMtx.Lock()
+	defer srv.routesMtx.Unlock()
+
+	if srv.routesLive {
+		// The server is running, so build a new route table and swap it
+		rtr := rt.buildRouter(rt.routes)
+		rtr.Handle(method, path, r.fastHandler)
+		rt.router.Store(rtr)
+	} else {
+		rt.router.Load().Handle(method, path, r.fastHandler)
+	}
+	rt.routes = append(rt.routes, r)
+
+	// Done
+	return r
+}
+
+func (rt *routeTable) removeRoute(r *Route) bool {
+	srv := rt.srv
+
+	srv.routesMtx.Lock()
+	defer srv.routesMtx.Unlock()
+
+	for idx, other := range rt.routes {
+		if other == r {
+			routes := make([]*Route, 0, len(rt.routes)-1)
+			routes = append(routes, rt.routes[:idx]...)
+			routes = append(routes, rt.routes[idx+1:]...)
+
+			rt.router.Store(rt.buildRouter(routes))
+			rt.routes = routes
+			if r.maxBodySize > 0 || r.continueHandler != nil {
+				rt.updateHeaderRoutes()
+			}
+			if len(r.name) > 0 {
+				delete(srv.namedRoutes, r.name)
+			}
+			return true
+		}
+	}
+
+	// Not found
+	return false
+}
+
+func (rt *routeTable) findRoute(method string, path string) *Route {
+	rt.srv.routesMtx.RLock()
+	defer rt.srv.routesMtx.RUnlock()
+
+	for _, r := range rt.routes {
+		if r.method == method && r.path == path {
+			return r
+		}
+	}
+	return nil
+}
+
+// use appends a middleware to the table. The caller must hold the server's routesMtx.
+func (rt *routeTable) use(middleware HandlerFunc) {
+	m := make([]HandlerFunc, 0, len(rt.middlewares)+1)
+	m = append(m, rt.middlewares...)
+	m = append(m, middleware)
+	rt.middlewares = m
+}
+
+// updateChain publishes the middleware chain to run before the router. Virtual hosts run the server-wide
+// middlewares first. The caller must hold the server's routesMtx or the table must not be in use yet.
+func (rt *routeTable) updateChain() {
+	var chain []HandlerFunc
+
+	if rt.host != nil {
+		chain = make([]HandlerFunc, 0, len(rt.srv.defaultRoutes.middlewares)+len(rt.middlewares))
+		chain = append(chain, rt.srv.defaultRoutes.middlewares...)
+	} else {
+		chain = make([]HandlerFunc, 0, len(rt.middlewares))
+	}
+	chain = append(chain, rt.middlewares...)
+	rt.chain.Store(&chain)
+}
+
+// updateFallbackHandlers rebuilds the router after changing the not found or method not allowed handlers. The
+// caller must hold the server's routesMtx.
+func (rt *routeTable) updateFallbackHandlers() {
+	rt.router.Store(rt.buildRouter(rt.routes))
+}
+
+// updateHeaderRoutes rebuilds the router used to find the settings of routes that must be applied before the body
+// is read. The caller must hold the server's routesMtx.
+func (rt *routeTable) updateHeaderRoutes() {
+	var rtr *router.Router
+
+	for _, r := range rt.routes {
+		if r.maxBodySize > 0 || r.continueHandler != nil {
+			if rtr == nil {
+				rtr = router.New()
+			}
+			hr := &headerRoute{
+				maxBodySize:     r.maxBodySize,
+				continueHandler: r.continueHandler,
+			}
+			rtr.Handle(r.method, r.path, func(ctx *fasthttp.RequestCtx) {
+				ctx.SetUserValue(headerRouteKey, hr)
+			})
+		}
+	}
+	rt.headerRoutes.Store(rtr)
+}
+
+// findHeaderRoute returns the settings of the route that will handle the request, if it has any that must be
+// applied before the body is read. The route parameters are stored in the provided context.
+func (rt *routeTable) findHeaderRoute(method []byte, requestURI []byte, ctx *fasthttp.RequestCtx) *headerRoute {
+	rtr := rt.headerRoutes.Load()
+	if rtr == nil {
+		return nil
+	}
+
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+	if uri.Parse(nil, requestURI) != nil {
+		return nil
+	}
+
+	h, _ := rtr.Lookup(string(method), string(uri.Path()), ctx)
+	if h == nil {
+		return nil
+	}
+	h(ctx)
+	hr, _ := ctx.UserValue(headerRouteKey).(*headerRoute)
+	ctx.RemoveUserValue(headerRouteKey)
+
+	// Done
+	return hr
+}
+
+// notFound executes the current not found handler of the table.
+func (rt *routeTable) notFound(ctx *fasthttp.RequestCtx) {
+	rt.router.Load().NotFound(ctx)
+}
+
+func (rt *routeTable) newRouter() *router.Router {
+	rtr := router.New()
+
+	// Override some router settings
+	rtr.RedirectTrailingSlash = true
+	rtr.RedirectFixedPath = true
+	rtr.HandleMethodNotAllowed = true
+	rtr.HandleOPTIONS = false
+	rtr.NotFound = rt.notFoundHandler
+	rtr.MethodNotAllowed = rt.methodNotAllowedHandler
+
+	// Done
+	return rtr
+}
+
+func (rt *routeTable) buildRouter(routes []*Route) *router.Router {
+	rtr := rt.newRouter()
+	for _, r := range routes {
+		rtr.Handle(r.method, r.path, r.fastHandler)
+	}
+	return rtr
+}
+
+// -----------------------------------------------------------------------------
+
+func (srv *Server) setRoutesLive() {
+	srv.routesMtx.Lock()
+	srv.routesLive = true
+	srv.routesMtx.Unlock()
+}
@@ -0,0 +1,24 @@
+// See the LICENSE file for license details.
+
+//go:build !unix
+
+package go_webserver
+
+import (
+	"errors"
+	"net"
+)
+
+// -----------------------------------------------------------------------------
+
+// SystemdListeners returns the listeners inherited through systemd's socket activation protocol.
+// NOTE: Not supported on this platform.
+func SystemdListeners(_ bool) ([]net.Listener, error) {
+	return nil, errors.New("systemd socket activation is not supported on this platform")
+}
+
+// SystemdNamedListeners returns the inherited listeners grouped by name.
+// NOTE: Not supported on this platform.
+func SystemdNamedListeners(_ bool) (map[string][]net.Listener, error) {
+	return nil, errors.New("systemd socket activation is not supported on this platform")
+}
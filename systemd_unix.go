@@ -0,0 +1,107 @@
+// See the LICENSE file for license details.
+
+//go:build unix
+
+package go_webserver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	// systemdListenFdsStart is the first file descriptor passed by systemd. See sd_listen_fds(3).
+	systemdListenFdsStart = 3
+)
+
+// -----------------------------------------------------------------------------
+
+// SystemdListeners returns the listeners inherited through systemd's socket activation protocol. If the process
+// was not socket-activated, an empty list is returned. If unsetEnv is true, the LISTEN_PID, LISTEN_FDS and
+// LISTEN_FDNAMES environment variables are removed so child processes do not inherit them.
+func SystemdListeners(unsetEnv bool) ([]net.Listener, error) {
+	files := systemdFiles(unsetEnv)
+
+	lns := make([]net.Listener, 0, len(files))
+	for _, f := range files {
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			closeListeners(lns)
+			return nil, fmt.Errorf("unable to use inherited socket %v [err=%w]", f.Name(), err)
+		}
+		lns = append(lns, ln)
+	}
+
+	// Done
+	return lns, nil
+}
+
+// SystemdNamedListeners is like SystemdListeners but returns the inherited listeners grouped by the name assigned
+// in the FileDescriptorName setting of the socket unit. Unnamed sockets are stored under the "unknown" key.
+func SystemdNamedListeners(unsetEnv bool) (map[string][]net.Listener, error) {
+	files := systemdFiles(unsetEnv)
+
+	lns := make(map[string][]net.Listener)
+	for _, f := range files {
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range lns {
+				closeListeners(l)
+			}
+			return nil, fmt.Errorf("unable to use inherited socket %v [err=%w]", f.Name(), err)
+		}
+		lns[f.Name()] = append(lns[f.Name()], ln)
+	}
+
+	// Done
+	return lns, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func systemdFiles(unsetEnv bool) []*os.File {
+	if unsetEnv {
+		defer func() {
+			_ = os.Unsetenv("LISTEN_PID")
+			_ = os.Unsetenv("LISTEN_FDS")
+			_ = os.Unsetenv("LISTEN_FDNAMES")
+		}()
+	}
+
+	// Check if the sockets were passed to us
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make([]*os.File, 0, count)
+	for idx := 0; idx < count; idx++ {
+		fd := systemdListenFdsStart + idx
+
+		// Avoid leaking the descriptors to child processes
+		syscall.CloseOnExec(fd)
+
+		name := "unknown"
+		if idx < len(names) && len(names[idx]) > 0 {
+			name = names[idx]
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+
+	// Done
+	return files
+}
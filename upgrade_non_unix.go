@@ -0,0 +1,35 @@
+// See the LICENSE file for license details.
+
+//go:build !unix
+
+package go_webserver
+
+import (
+	"errors"
+	"net"
+)
+
+// -----------------------------------------------------------------------------
+
+// Upgrade executes a new instance of the running binary and hands off the server listeners to it.
+// NOTE: Not supported on this platform.
+func (srv *Server) Upgrade() error {
+	return errors.New("binary upgrades are not supported on this platform")
+}
+
+// UpgradeReady must be called by the new process of a binary upgrade once all of its servers are started.
+// NOTE: Not supported on this platform.
+func UpgradeReady() {
+}
+
+// -----------------------------------------------------------------------------
+
+func (srv *Server) watchUpgradeSignal() {
+}
+
+func (srv *Server) stopWatchingUpgradeSignal() {
+}
+
+func takeInheritedListener(_ string) net.Listener {
+	return nil
+}
@@ -0,0 +1,260 @@
+// See the LICENSE file for license details.
+
+//go:build unix
+
+package go_webserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+const (
+	upgradeEnvListeners = "GO_WEBSERVER_UPGRADE_LISTENERS"
+	upgradeEnvReadyFd   = "GO_WEBSERVER_UPGRADE_READY_FD"
+
+	// upgradeListenFdsStart is the first file descriptor number assigned to exec.Cmd.ExtraFiles.
+	upgradeListenFdsStart = 3
+)
+
+// -----------------------------------------------------------------------------
+
+var (
+	inheritedListenersOnce sync.Once
+	inheritedListenersMtx  sync.Mutex
+	inheritedListeners     map[string]net.Listener
+	upgradeReadyFile       *os.File
+	upgradeReadyOnce       sync.Once
+)
+
+// -----------------------------------------------------------------------------
+
+// Upgrade executes a new instance of the running binary, with the same arguments and environment, and hands off
+// the server listeners to it. The new process must create servers with the same listen endpoints, which reuse the
+// inherited sockets when started, and call UpgradeReady once all of them are running. Once notified, this server
+// is gracefully stopped. The caller is responsible for exiting the process after a successful upgrade.
+func (srv *Server) Upgrade() error {
+	if atomic.LoadInt32(&srv.state) != stateRunning {
+		return errors.New("server is not running")
+	}
+	if !atomic.CompareAndSwapInt32(&srv.upgrading, 0, 1) {
+		return errors.New("an upgrade is already in progress")
+	}
+	defer atomic.StoreInt32(&srv.upgrading, 0)
+
+	// Duplicate the listening sockets so they can be passed to the new process
+	files := make([]*os.File, 0, len(srv.listeners))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	names := make([]string, 0, len(srv.listeners))
+	for idx, ln := range srv.listeners {
+		fl, ok := ln.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return fmt.Errorf("listener %v cannot be handed off", srv.listenerNames[idx])
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("unable to duplicate listener %v [err=%w]", srv.listenerNames[idx], err)
+		}
+		files = append(files, f)
+		names = append(names, url.QueryEscape(srv.listenerNames[idx]))
+	}
+
+	// Create the pipe the new process will use to tell us it is ready
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = readyR.Close()
+	}()
+
+	// Execute the new process
+	exe, err := os.Executable()
+	if err != nil {
+		_ = readyW.Close()
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = make([]string, 0)
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, upgradeEnvListeners+"=") && !strings.HasPrefix(e, upgradeEnvReadyFd+"=") {
+			cmd.Env = append(cmd.Env, e)
+		}
+	}
+	cmd.Env = append(cmd.Env,
+		upgradeEnvListeners+"="+strings.Join(names, ","),
+		upgradeEnvReadyFd+"="+strconv.Itoa(upgradeListenFdsStart+len(files)),
+	)
+	cmd.ExtraFiles = append(files, readyW)
+
+	err = cmd.Start()
+	_ = readyW.Close()
+	if err != nil {
+		return fmt.Errorf("unable to execute the new process [err=%w]", err)
+	}
+
+	// Wait until the new process is ready. If it exits prematurely, the pipe is closed, and we get an error.
+	readyCh := make(chan error, 1)
+	go func() {
+		var buf [1]byte
+
+		_, err2 := readyR.Read(buf[:])
+		if err2 == io.EOF {
+			err2 = errors.New("the new process exited prematurely")
+		}
+		readyCh <- err2
+	}()
+
+	select {
+	case err = <-readyCh:
+	case <-time.After(srv.upgradeTimeout):
+		err = errors.New("timeout while waiting for the new process to be ready")
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	_ = cmd.Process.Release()
+
+	// The new process owns the sockets now so avoid removing the Unix domain socket files on close
+	for _, ln := range srv.listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	// Gracefully shut down this server
+	srv.Stop()
+
+	// Done
+	return nil
+}
+
+// UpgradeReady must be called by the new process of a binary upgrade, once all of its servers are started, to
+// close the inherited listeners no server claimed and let the parent process know it can stop. It does nothing
+// if the process was not started by Server.Upgrade or if it was already called.
+func UpgradeReady() {
+	upgradeReadyOnce.Do(func() {
+		inheritedListenersOnce.Do(loadInheritedListeners)
+
+		inheritedListenersMtx.Lock()
+		defer inheritedListenersMtx.Unlock()
+
+		for name, ln := range inheritedListeners {
+			_ = ln.Close()
+			delete(inheritedListeners, name)
+		}
+
+		if upgradeReadyFile != nil {
+			_, _ = upgradeReadyFile.Write([]byte{1})
+			_ = upgradeReadyFile.Close()
+			upgradeReadyFile = nil
+		}
+	})
+}
+
+// -----------------------------------------------------------------------------
+
+func (srv *Server) watchUpgradeSignal() {
+	if srv.upgradeSignal == nil {
+		return
+	}
+
+	srv.upgradeSignalCh = make(chan os.Signal, 1)
+	signal.Notify(srv.upgradeSignalCh, srv.upgradeSignal)
+
+	go func(ch chan os.Signal) {
+		for range ch {
+			err := srv.Upgrade()
+			if srv.upgradeHandler != nil {
+				srv.upgradeHandler(srv, err)
+			}
+		}
+	}(srv.upgradeSignalCh)
+}
+
+func (srv *Server) stopWatchingUpgradeSignal() {
+	if srv.upgradeSignalCh != nil {
+		signal.Stop(srv.upgradeSignalCh)
+		close(srv.upgradeSignalCh)
+		srv.upgradeSignalCh = nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func loadInheritedListeners() {
+	inheritedListeners = make(map[string]net.Listener)
+
+	value, ok := os.LookupEnv(upgradeEnvListeners)
+	if !ok {
+		return
+	}
+	readyFd, err := strconv.Atoi(os.Getenv(upgradeEnvReadyFd))
+	_ = os.Unsetenv(upgradeEnvListeners)
+	_ = os.Unsetenv(upgradeEnvReadyFd)
+
+	if len(value) > 0 {
+		for idx, n := range strings.Split(value, ",") {
+			fd := upgradeListenFdsStart + idx
+
+			// Avoid leaking the descriptors to child processes
+			syscall.CloseOnExec(fd)
+
+			name, err2 := url.QueryUnescape(n)
+			if err2 != nil {
+				name = n
+			}
+			f := os.NewFile(uintptr(fd), name)
+			ln, err2 := net.FileListener(f)
+			_ = f.Close()
+			if err2 == nil {
+				inheritedListeners[name] = ln
+			}
+		}
+	}
+
+	if err == nil && readyFd >= upgradeListenFdsStart {
+		syscall.CloseOnExec(readyFd)
+		upgradeReadyFile = os.NewFile(uintptr(readyFd), "upgrade-ready")
+	}
+}
+
+func takeInheritedListener(name string) net.Listener {
+	inheritedListenersOnce.Do(loadInheritedListeners)
+
+	inheritedListenersMtx.Lock()
+	defer inheritedListenersMtx.Unlock()
+
+	ln, ok := inheritedListeners[name]
+	if !ok {
+		return nil
+	}
+	delete(inheritedListeners, name)
+	return ln
+}
@@ -0,0 +1,230 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+
+// VirtualHost is a set of routes, middlewares and fallback handlers that are used when the request's host
+// matches the virtual host pattern.
+type VirtualHost struct {
+	srv     *Server
+	pattern string
+	rt      routeTable
+}
+
+type virtualHostSet struct {
+	exact     map[string]*VirtualHost
+	wildcards []*VirtualHost // Sorted from the most specific to the least one
+}
+
+// -----------------------------------------------------------------------------
+
+// Host returns the virtual host for the specified pattern, creating it if it does not exist. The pattern can be
+// an exact host name, like 'api.example.com', or a wildcard, like '*.example.com', which matches any subdomain.
+// The host is obtained using RequestContext.Host so the trusted proxies settings are honored. Requests whose
+// host does not match any virtual host are handled by the server's default routes.
+//
+// The server-wide middlewares added with Use are executed before the virtual host ones. By default, the
+// virtual host uses the server's not found and method not allowed handlers.
+func (srv *Server) Host(pattern string) *VirtualHost {
+	pattern = normalizeHost(pattern)
+	if len(pattern) == 0 || pattern == "*" || (strings.Contains(pattern, "*") && !strings.HasPrefix(pattern, "*.")) {
+		panic("invalid virtual host pattern")
+	}
+
+	srv.routesMtx.Lock()
+	defer srv.routesMtx.Unlock()
+
+	for _, vh := range srv.virtualHosts {
+		if vh.pattern == pattern {
+			return vh
+		}
+	}
+
+	vh := &VirtualHost{
+		srv:     srv,
+		pattern: pattern,
+	}
+	vh.rt.init(srv, vh, srv.defaultRoutes.notFoundHandler, srv.defaultRoutes.methodNotAllowedHandler)
+
+	srv.virtualHosts = append(srv.virtualHosts, vh)
+	srv.updateVirtualHostSet()
+
+	// Done
+	return vh
+}
+
+// Pattern returns the host pattern of the virtual host
+func (vh *VirtualHost) Pattern() string {
+	return vh.pattern
+}
+
+// Use adds a middleware that will be executed for the requests handled by this virtual host
+func (vh *VirtualHost) Use(middleware HandlerFunc) {
+	vh.srv.routesMtx.Lock()
+	defer vh.srv.routesMtx.Unlock()
+
+	vh.rt.use(middleware)
+	vh.rt.updateChain()
+}
+
+// NotFound sets a custom handler for 404 errors of this virtual host
+func (vh *VirtualHost) NotFound(handler HandlerFunc) {
+	vh.srv.routesMtx.Lock()
+	defer vh.srv.routesMtx.Unlock()
+
+	vh.rt.notFoundHandler = vh.srv.createEndpointHandler(nil, handler, nil, nil)
+	vh.rt.updateFallbackHandlers()
+}
+
+// MethodNotAllowed sets a custom handler for 405 errors of this virtual host
+func (vh *VirtualHost) MethodNotAllowed(handler HandlerFunc) {
+	vh.srv.routesMtx.Lock()
+	defer vh.srv.routesMtx.Unlock()
+
+	vh.rt.methodNotAllowedHandler = vh.srv.createEndpointHandler(nil, handler, nil, nil)
+	vh.rt.updateFallbackHandlers()
+}
+
+// Group creates a new route group within the virtual host
+func (vh *VirtualHost) Group(prefix string, middlewares ...HandlerFunc) *RouteGroup {
+	return &RouteGroup{
+		rt:          &vh.rt,
+		prefix:      joinGroupPrefix("", prefix),
+		middlewares: append(make([]HandlerFunc, 0, len(middlewares)), middlewares...),
+	}
+}
+
+// GET adds a GET handler for the specified route
+func (vh *VirtualHost) GET(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("GET", path, handler, nil, middlewares)
+}
+
+// HEAD adds a HEAD handler for the specified route
+func (vh *VirtualHost) HEAD(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("HEAD", path, handler, nil, middlewares)
+}
+
+// OPTIONS adds a OPTIONS handler for the specified route
+func (vh *VirtualHost) OPTIONS(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("OPTIONS", path, handler, nil, middlewares)
+}
+
+// POST adds a POST handler for the specified route
+func (vh *VirtualHost) POST(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("POST", path, handler, nil, middlewares)
+}
+
+// PUT adds a PUT handler for the specified route
+func (vh *VirtualHost) PUT(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("PUT", path, handler, nil, middlewares)
+}
+
+// PATCH adds a PATCH handler for the specified route
+func (vh *VirtualHost) PATCH(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("PATCH", path, handler, nil, middlewares)
+}
+
+// DELETE adds a DELETE handler for the specified route
+func (vh *VirtualHost) DELETE(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute("DELETE", path, handler, nil, middlewares)
+}
+
+// CustomMethod adds a custom method handler for the specified route
+func (vh *VirtualHost) CustomMethod(method string, path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return vh.rt.addRoute(method, path, handler, nil, middlewares)
+}
+
+// ServeFiles adds custom filesystem handler for the specified route
+func (vh *VirtualHost) ServeFiles(path string, opts ServerFilesOptions, middlewares ...HandlerFunc) error {
+	return vh.rt.serveFiles(path, opts, nil, middlewares)
+}
+
+// ServeDebugProfiles adds the GO runtime profile handlers to the virtual host
+func (vh *VirtualHost) ServeDebugProfiles(basePath string, middlewares ...HandlerFunc) {
+	vh.rt.serveDebugProfiles(basePath, nil, middlewares)
+}
+
+// WebSocket adds a WebSocket endpoint to the virtual host. See Server.WebSocket for details.
+func (vh *VirtualHost) WebSocket(path string, handler WebSocketHandler, middlewares ...HandlerFunc) *Route {
+	return vh.rt.webSocket(path, handler, nil, middlewares)
+}
+
+// RemoveRoute removes the route registered with the specified method and path pattern. See Server.RemoveRoute.
+func (vh *VirtualHost) RemoveRoute(method string, path string) bool {
+	r := vh.rt.findRoute(method, path)
+	if r == nil {
+		return false
+	}
+	return r.Remove()
+}
+
+// -----------------------------------------------------------------------------
+
+// updateVirtualHostSet publishes a new snapshot of the virtual hosts lookup table. The caller must hold the
+// server's routesMtx.
+func (srv *Server) updateVirtualHostSet() {
+	set := &virtualHostSet{
+		exact:     make(map[string]*VirtualHost),
+		wildcards: make([]*VirtualHost, 0),
+	}
+	for _, vh := range srv.virtualHosts {
+		if strings.HasPrefix(vh.pattern, "*.") {
+			set.wildcards = append(set.wildcards, vh)
+		} else {
+			set.exact[vh.pattern] = vh
+		}
+	}
+	sort.SliceStable(set.wildcards, func(i, j int) bool {
+		return len(set.wildcards[i].pattern) > len(set.wildcards[j].pattern)
+	})
+	srv.hosts.Store(set)
+}
+
+// selectRouteTable returns the route table to use for the given request.
+func (srv *Server) selectRouteTable(req *RequestContext) *routeTable {
+	if srv.hosts.Load() == nil {
+		return &srv.defaultRoutes
+	}
+	return srv.selectRouteTableByHost(req.Host())
+}
+
+// selectRouteTableByHost returns the route table to use for the given host.
+func (srv *Server) selectRouteTableByHost(host string) *routeTable {
+	set := srv.hosts.Load()
+	if set == nil {
+		return &srv.defaultRoutes
+	}
+
+	host = normalizeHost(host)
+	if vh, ok := set.exact[host]; ok {
+		return &vh.rt
+	}
+	for _, vh := range set.wildcards {
+		// The pattern starts with '*' so the suffix includes the dot
+		if len(host) > len(vh.pattern)-1 && strings.HasSuffix(host, vh.pattern[1:]) {
+			return &vh.rt
+		}
+	}
+
+	// No match, use the default one
+	return &srv.defaultRoutes
+}
+
+// -----------------------------------------------------------------------------
+
+func normalizeHost(host string) string {
+	// Remove the port if present
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
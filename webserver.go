@@ -3,19 +3,22 @@
 package go_webserver
 
 import (
+	"context"
 	"crypto/tls"
 	"embed"
 	"errors"
+	"fmt"
 	"io/fs"
+	"log/slog"
 	"net"
+	"os"
 	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/fasthttp/router"
+	"github.com/mxmauro/go-webserver/v2/proxy_protocol"
 	"github.com/mxmauro/go-webserver/v2/trusted_proxy"
 	"github.com/mxmauro/go-webserver/v2/util"
 	"github.com/valyala/fasthttp"
@@ -32,20 +35,43 @@ type RequestErrorHandler func(req *RequestContext, err error)
 // HandlerFunc defines a function that handles a request.
 type HandlerFunc func(req *RequestContext) error
 
+// UpgradeHandler is a callback to call when a signal-triggered binary upgrade finishes. If err is nil, the
+// listeners were handed off to the new process and the server was stopped.
+type UpgradeHandler func(srv *Server, err error)
+
 // Server is the main server object
 type Server struct {
 	fastserver             fasthttp.Server
-	router                 *router.Router
-	bindAddress            net.IP
-	bindPort               uint16
+	routesMtx              sync.RWMutex
+	routesLive             bool
+	defaultRoutes          routeTable
+	virtualHosts           []*VirtualHost
+	hosts                  atomic.Pointer[virtualHostSet]
+	namedRoutes            map[string]*Route
+	endpoints              []listenEndpoint
+	listeners              []net.Listener
+	listenerNames          []string
 	listenErrorHandler     ListenErrorHandler
 	requestErrorHandler    RequestErrorHandler
-	middlewares            []HandlerFunc
+	continueHandler        ContinueHandler
+	logger                 *slog.Logger
 	state                  int32
-	startShutdownSignal    chan struct{}
+	startShutdownSignal    chan context.Context
 	shutdownCompleteSignal chan struct{}
+	shutdownErr            error
+	shutdownTimeout        time.Duration
+	shutdownGracePeriod    time.Duration
 	requestCtxPool         *RequestContextPool
+	conns                  connTracker
 	trustedProxy           *trusted_proxy.TrustedProxy
+	proxyProtocol          *proxy_protocol.Options
+	upgradeSignal          os.Signal
+	upgradeSignalCh        chan os.Signal
+	upgradeTimeout         time.Duration
+	upgradeHandler         UpgradeHandler
+	upgrading              int32
+	webSocketOpts          WebSocketOptions
+	webSockets             webSocketSet
 }
 
 // Options specifies the server creation options.
@@ -54,11 +80,17 @@ type Options struct {
 	Name string
 
 	// Address is the bind address to attach the server listener.
+	// NOTE: Address and Port can be omitted if, at least, one ListenEndpoints is provided or if the server
+	//       will run on already opened listeners by calling Serve.
 	Address string
 
 	// Port is the port number the server will listen.
 	Port uint16
 
+	// ListenEndpoints is an optional list of extra endpoints, including Unix domain sockets, where the server will
+	// accept connections. All of them share the same routes and middlewares and are started and stopped together.
+	ListenEndpoints []ListenEndpoint
+
 	// ReadTimeout is the amount of time allowed to read the full request including body. The connection's read
 	// deadline is reset when the connection opens, or for keep-alive connections after the first byte has been read.
 	ReadTimeout time.Duration
@@ -76,7 +108,17 @@ type Options struct {
 	// Closes incoming connections after sending the first response to client.
 	DisableKeepalive bool
 
+	// ShutdownTimeout is the maximum amount of time Stop will wait for in-flight requests to complete.
+	// Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// ShutdownGracePeriod is an optional amount of time to wait, when the server is being stopped, before closing
+	// the listeners. During this period, the server continues accepting requests but IsReady returns false so load
+	// balancers can stop routing new traffic to it.
+	ShutdownGracePeriod time.Duration
+
 	// Enable request body streaming and call the handler sooner when given body is larger than the current limit.
+	// Use RequestContext.MultipartReader to process large uploads without buffering them.
 	StreamRequestBody bool
 
 	// Disable Multipart Form data parsing and return the binary blob instead.
@@ -85,16 +127,30 @@ type Options struct {
 	// A callback to call if an error is encountered.
 	ListenErrorHandler ListenErrorHandler
 
-	// A callback to handle errors in requests.
+	// A callback to handle errors in requests. Defaults to a handler that sends the error using
+	// RequestContext.SendError.
 	RequestErrorHandler RequestErrorHandler
 
+	// Logger receives the errors of the underlying fasthttp server, like connection resets, too large headers or
+	// TLS handshake failures, and the ones sent by the default RequestErrorHandler. It is also the base of
+	// RequestContext.Log. Defaults to a logger that discards everything.
+	Logger *slog.Logger
+
+	// ContinueHandler, if set, is called for requests sent with the "Expect: 100-continue" header before their body
+	// is read, so authorization or quota checks can reject them using the headers only. Use Route.ContinueHandler
+	// to add per-route checks. Rejected requests get a 417 status. Regardless of the hooks, requests whose
+	// Content-Length exceeds the maximum body size are rejected with a 413 status, without reading the body, unless
+	// StreamRequestBody is enabled.
+	ContinueHandler ContinueHandler
+
 	// A custom handler for 404 errors
 	NotFoundHandler HandlerFunc
 
 	// A custom handler for 405 errors
 	MethodNotAllowedHandler HandlerFunc
 
-	// TLSConfig optionally provides a TLS configuration for use.
+	// TLSConfig optionally provides a TLS configuration for use. To rotate certificates without restarting the
+	// server, set the GetCertificate callback, for e.g., using the cert_manager package.
 	TLSConfig *tls.Config
 
 	// If MinReqFileDescs is greater than zero, specifies the minimum number of required file descriptors
@@ -112,6 +168,25 @@ type Options struct {
 	//   2. RemoteIP: The value on ProxyHeader header will be used.
 	//   3. Host:     The value from X-Forwarded-Host header will be used.
 	TrustedProxies []string
+
+	// ProxyProtocol, if defined, enables parsing of PROXY protocol v1/v2 headers sent by load balancers like
+	// AWS NLB or HAProxy, on connections coming from trusted sources. The client address sent in the header is
+	// returned by RequestContext's RemoteAddr and RemoteIP methods.
+	ProxyProtocol *proxy_protocol.Options
+
+	// UpgradeSignal, if not nil, is a signal that triggers a graceful binary upgrade. See Server.Upgrade for details.
+	// NOTE: Only available on *nix operating systems.
+	UpgradeSignal os.Signal
+
+	// UpgradeTimeout is the maximum amount of time to wait for the new process to be ready when upgrading.
+	// Defaults to 1 minute.
+	UpgradeTimeout time.Duration
+
+	// A callback to call when a signal-triggered upgrade finishes.
+	UpgradeHandler UpgradeHandler
+
+	// WebSocket sets the parameters to use in WebSocket endpoints.
+	WebSocket WebSocketOptions
 }
 
 // ServerFilesOptions sets the parameters to use in a ServeFiles call
@@ -140,6 +215,8 @@ const (
 	DefaultReadTimeout        = 10 * time.Second
 	DefaultWriteTimeout       = 10 * time.Second
 	DefaultMaxRequestBodySize = 4 * 1048576 // 4MB
+	DefaultUpgradeTimeout     = time.Minute
+	DefaultShutdownTimeout    = 5 * time.Second
 )
 
 // -----------------------------------------------------------------------------
@@ -155,11 +232,24 @@ const (
 // Create creates a new webserver
 func Create(opts Options) (*Server, error) {
 	// Check options
-	if len(opts.Address) == 0 {
-		return nil, errors.New("invalid server bind address")
+	endpoints := make([]listenEndpoint, 0, len(opts.ListenEndpoints)+1)
+	if len(opts.Address) > 0 {
+		if opts.Port < 1 || opts.Port > 65535 {
+			return nil, errors.New("invalid server port")
+		}
+
+		parsedBindAddress := net.ParseIP(opts.Address)
+		if parsedBindAddress == nil {
+			return nil, errors.New("invalid server bind address")
+		}
+		endpoints = append(endpoints, newTcpListenEndpoint(parsedBindAddress, opts.Port))
 	}
-	if opts.Port < 1 || opts.Port > 65535 {
-		return nil, errors.New("invalid server port")
+	for _, ep := range opts.ListenEndpoints {
+		parsedEndpoint, err := parseListenEndpoint(ep)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, parsedEndpoint)
 	}
 
 	readTimeout := opts.ReadTimeout
@@ -183,12 +273,22 @@ func Create(opts Options) (*Server, error) {
 		maxRequestBodySize = DefaultMaxRequestBodySize
 	}
 
-	parsedBindAddress := net.ParseIP(opts.Address)
-	if parsedBindAddress == nil {
-		return nil, errors.New("invalid server bind address")
+	shutdownTimeout := opts.ShutdownTimeout
+	if shutdownTimeout < time.Duration(0) {
+		return nil, errors.New("invalid shutdown timeout")
+	} else if shutdownTimeout == time.Duration(0) {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+
+	if opts.ShutdownGracePeriod < time.Duration(0) {
+		return nil, errors.New("invalid shutdown grace period")
 	}
-	if p4 := parsedBindAddress.To4(); len(p4) == net.IPv4len {
-		parsedBindAddress = p4
+
+	upgradeTimeout := opts.UpgradeTimeout
+	if upgradeTimeout < time.Duration(0) {
+		return nil, errors.New("invalid upgrade timeout")
+	} else if upgradeTimeout == time.Duration(0) {
+		upgradeTimeout = DefaultUpgradeTimeout
 	}
 
 	if opts.MinReqFileDescs > 0 && util.CheckMaxFileDescriptors(opts.MinReqFileDescs) == false {
@@ -197,52 +297,67 @@ func Create(opts Options) (*Server, error) {
 
 	// Create a new server container
 	srv := &Server{
-		router:                 router.New(),
-		bindAddress:            parsedBindAddress,
-		bindPort:               opts.Port,
+		namedRoutes:            make(map[string]*Route),
+		endpoints:              endpoints,
 		listenErrorHandler:     opts.ListenErrorHandler,
 		requestErrorHandler:    opts.RequestErrorHandler,
-		middlewares:            make([]HandlerFunc, 0),
+		continueHandler:        opts.ContinueHandler,
+		logger:                 opts.Logger,
 		state:                  stateNotStarted,
-		startShutdownSignal:    make(chan struct{}, 1),
-		shutdownCompleteSignal: make(chan struct{}, 1),
+		startShutdownSignal:    make(chan context.Context, 1),
+		shutdownCompleteSignal: make(chan struct{}),
+		shutdownTimeout:        shutdownTimeout,
+		shutdownGracePeriod:    opts.ShutdownGracePeriod,
 		requestCtxPool:         newRequestContextPool(),
+		upgradeSignal:          opts.UpgradeSignal,
+		upgradeTimeout:         upgradeTimeout,
+		upgradeHandler:         opts.UpgradeHandler,
+		webSocketOpts:          opts.WebSocket,
 	}
 	if len(opts.TrustedProxies) > 0 {
 		srv.trustedProxy = trusted_proxy.NewTrustedProxy(opts.TrustedProxies)
 	}
+	if opts.ProxyProtocol != nil {
+		if len(opts.ProxyProtocol.TrustedSources) == 0 {
+			return nil, errors.New("no trusted sources for the proxy protocol were specified")
+		}
+		pp := *opts.ProxyProtocol
+		srv.proxyProtocol = &pp
+	}
+
+	// Set the default logger if none was specified.
+	if srv.logger == nil {
+		srv.logger = slog.New(discardHandler{})
+	}
 
 	// Set default request error handler if none was specified.
 	if srv.requestErrorHandler == nil {
-		srv.requestErrorHandler = func(req *RequestContext, err error) {
-			req.InternalServerError(err.Error())
-		}
+		srv.requestErrorHandler = defaultRequestErrorHandler
 	}
 
-	// Override some router settings
-	srv.router.RedirectTrailingSlash = true
-	srv.router.RedirectFixedPath = true
-	srv.router.HandleMethodNotAllowed = true
-	srv.router.HandleOPTIONS = false
-
 	// Set the endpoint not found handler
+	var notFoundHandler fasthttp.RequestHandler
 	if opts.NotFoundHandler != nil {
-		srv.router.NotFound = srv.createEndpointHandler(opts.NotFoundHandler)
+		notFoundHandler = srv.createEndpointHandler(nil, opts.NotFoundHandler, nil, nil)
 	} else {
-		srv.router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		notFoundHandler = func(ctx *fasthttp.RequestCtx) {
 			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusNotFound), fasthttp.StatusNotFound)
 		}
 	}
 
 	// Set the method not allowed handler
+	var methodNotAllowedHandler fasthttp.RequestHandler
 	if opts.MethodNotAllowedHandler != nil {
-		srv.router.MethodNotAllowed = srv.createEndpointHandler(opts.MethodNotAllowedHandler)
+		methodNotAllowedHandler = srv.createEndpointHandler(nil, opts.MethodNotAllowedHandler, nil, nil)
 	} else {
-		srv.router.MethodNotAllowed = func(ctx *fasthttp.RequestCtx) {
+		methodNotAllowedHandler = func(ctx *fasthttp.RequestCtx) {
 			ctx.Error(fasthttp.StatusMessage(fasthttp.StatusMethodNotAllowed), fasthttp.StatusMethodNotAllowed)
 		}
 	}
 
+	// Create the initial, empty, route table and middleware chain
+	srv.defaultRoutes.init(srv, nil, notFoundHandler, methodNotAllowedHandler)
+
 	// Check server name
 	serverName := opts.Name
 	if len(serverName) == 0 {
@@ -259,8 +374,15 @@ func Create(opts Options) (*Server, error) {
 		DisableKeepalive:   opts.DisableKeepalive,
 		MaxRequestBodySize: maxRequestBodySize,
 		TLSConfig:          opts.TLSConfig,
-		Logger:             newSilentLogger(),
+		Logger:             newLoggerBridge(srv.logger),
 		CloseOnShutdown:    true,
+
+		StreamRequestBody:            opts.StreamRequestBody,
+		DisablePreParseMultipartForm: opts.DisablePreParseMultipartForm,
+		HeaderReceived:               srv.onHeaderReceived,
+		ContinueHandler:              srv.onContinue,
+		ErrorHandler:                 onParseError,
+		ConnState:                    srv.conns.onConnState,
 	}
 
 	// Done
@@ -273,96 +395,181 @@ func (srv *Server) Start() error {
 		return errors.New("server is not stopped")
 	}
 
-	// Create the listener
-	var network string
-
-	// "tcp" network is not supported by all platforms
-	address := srv.bindAddress.String()
-	if len(srv.bindAddress) == net.IPv4len {
-		network = "tcp4"
-	} else {
-		network = "tcp6"
-		address = "[" + address + "]"
+	if len(srv.endpoints) == 0 {
+		srv.setState(stateNotStarted)
+		return errors.New("no listen endpoints were specified")
 	}
 
-	// Create the graceful shutdown listener
-	ln, err := createListener(network, address+":"+strconv.Itoa(int(srv.bindPort)))
-	if err != nil {
-		srv.setState(stateNotStarted)
-		return err
+	// Create the listeners
+	lns := make([]net.Listener, 0, len(srv.endpoints))
+	for idx := range srv.endpoints {
+		// If we are the new process of a binary upgrade, reuse the listener handed off by our parent
+		ln := takeInheritedListener(srv.endpoints[idx].String())
+		if ln == nil {
+			var err error
+
+			ln, err = srv.endpoints[idx].listen()
+			if err != nil {
+				closeListeners(lns)
+				srv.setState(stateNotStarted)
+				return fmt.Errorf("unable to listen on %v [err=%w]", srv.endpoints[idx].String(), err)
+			}
+		}
+		lns = append(lns, ln)
 	}
 
-	// Wrap listener into a TLS listener if a TLS configuration was specified
-	if srv.fastserver.TLSConfig != nil {
-		ln = tls.NewListener(ln, srv.fastserver.TLSConfig.Clone())
+	// Start accepting connections and run in background until shutdown or error
+	srv.serve(lns)
+
+	// Done
+	return nil
+}
+
+// Serve initiates accepting connections on the provided, already opened, listeners instead of the ones specified
+// in the options. Like Start, it returns immediately and the server runs in background until stopped. The
+// listeners are closed when the server shuts down.
+func (srv *Server) Serve(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners were specified")
+	}
+	if !atomic.CompareAndSwapInt32(&srv.state, stateNotStarted, stateStarting) {
+		return errors.New("server is not stopped")
 	}
 
 	// Start accepting connections and run in background until shutdown or error
-	srv.serve(ln)
+	srv.serve(listeners)
 
 	// Done
 	return nil
 }
 
-// Stop shuts down the web server
+// Stop shuts down the web server. It waits up to the configured shutdown timeout for in-flight requests to complete.
 func (srv *Server) Stop() {
-	if atomic.CompareAndSwapInt32(&srv.state, stateRunning, stateStopping) {
-		srv.startShutdownSignal <- struct{}{}
+	ctx, ctxCancel := context.WithTimeout(context.Background(), srv.shutdownGracePeriod+srv.shutdownTimeout)
+	defer ctxCancel()
+
+	_ = srv.StopWithContext(ctx)
+}
+
+// StopWithContext shuts down the web server and waits until all in-flight requests are completed or the context
+// is done. If the shutdown grace period is set, it waits for it before closing the listeners. A nil error is
+// returned if all the in-flight requests were completed.
+func (srv *Server) StopWithContext(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&srv.state, stateRunning, stateStopping) {
+		// If another goroutine is stopping the server, wait until it finishes
+		if atomic.LoadInt32(&srv.state) == stateStopping {
+			select {
+			case <-srv.shutdownCompleteSignal:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	// Let load balancers notice we are not ready anymore
+	if srv.shutdownGracePeriod > 0 {
+		select {
+		case <-time.After(srv.shutdownGracePeriod):
+		case <-ctx.Done():
+		}
+	}
+
+	// Signal shutdown and wait until the server is really stopped
+	srv.startShutdownSignal <- ctx
+	<-srv.shutdownCompleteSignal
 
-		// Spin until server is really stopped
-		for atomic.LoadInt32(&srv.state) == stateStopping {
-			runtime.Gosched()
+	// Done
+	return srv.shutdownErr
+}
+
+// Wait blocks until the server is stopped.
+func (srv *Server) Wait() {
+	<-srv.shutdownCompleteSignal
+}
+
+// Logger returns the logger used by the server. Requests use it as the base of RequestContext.Log.
+func (srv *Server) Logger() *slog.Logger {
+	return srv.logger
+}
+
+// IsReady returns true if the server is running and not shutting down. It can be used in readiness probes.
+func (srv *Server) IsReady() bool {
+	return atomic.LoadInt32(&srv.state) == stateRunning
+}
+
+// ReadinessHandler returns a handler that responds with 200 if the server is ready to accept requests or 503 if
+// it is shutting down.
+func (srv *Server) ReadinessHandler() HandlerFunc {
+	return func(req *RequestContext) error {
+		if !srv.IsReady() {
+			req.ServiceUnavailable("")
+			return nil
 		}
+		req.NoContent(fasthttp.StatusOK)
+		return nil
 	}
 }
 
-// Use adds a middleware that will be executed as part of the request handler
+// Use adds a middleware that will be executed as part of the request handler. It can be safely called while
+// the server is running. In-flight requests continue using the previous middleware chain.
 func (srv *Server) Use(middleware HandlerFunc) {
-	srv.middlewares = append(srv.middlewares, middleware)
+	srv.routesMtx.Lock()
+	defer srv.routesMtx.Unlock()
+
+	srv.defaultRoutes.use(middleware)
+	srv.defaultRoutes.updateChain()
+	for _, vh := range srv.virtualHosts {
+		vh.rt.updateChain()
+	}
 }
 
 // GET adds a GET handler for the specified route
-func (srv *Server) GET(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("GET", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) GET(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("GET", path, handler, nil, middlewares)
 }
 
 // HEAD adds a HEAD handler for the specified route
-func (srv *Server) HEAD(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("HEAD", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) HEAD(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("HEAD", path, handler, nil, middlewares)
 }
 
 // OPTIONS adds a OPTIONS handler for the specified route
-func (srv *Server) OPTIONS(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("OPTIONS", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) OPTIONS(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("OPTIONS", path, handler, nil, middlewares)
 }
 
 // POST adds a POST handler for the specified route
-func (srv *Server) POST(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("POST", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) POST(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("POST", path, handler, nil, middlewares)
 }
 
 // PUT adds a PUT handler for the specified route
-func (srv *Server) PUT(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("PUT", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) PUT(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("PUT", path, handler, nil, middlewares)
 }
 
 // PATCH adds a PATCH handler for the specified route
-func (srv *Server) PATCH(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("PATCH", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) PATCH(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("PATCH", path, handler, nil, middlewares)
 }
 
 // DELETE adds a DELETE handler for the specified route
-func (srv *Server) DELETE(path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle("DELETE", path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) DELETE(path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute("DELETE", path, handler, nil, middlewares)
 }
 
 // CustomMethod adds a custom method handler for the specified route
-func (srv *Server) CustomMethod(method string, path string, handler HandlerFunc, middlewares ...HandlerFunc) {
-	srv.router.Handle(method, path, srv.createEndpointHandler(handler, middlewares...))
+func (srv *Server) CustomMethod(method string, path string, handler HandlerFunc, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.addRoute(method, path, handler, nil, middlewares)
 }
 
 // ServeFiles adds custom filesystem handler for the specified route
 func (srv *Server) ServeFiles(path string, opts ServerFilesOptions, middlewares ...HandlerFunc) error {
+	return srv.defaultRoutes.serveFiles(path, opts, nil, middlewares)
+}
+
+func (rt *routeTable) serveFiles(path string, opts ServerFilesOptions, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc) error {
 	var err error
 	var isEmbedFS bool
 
@@ -397,10 +604,10 @@ func (srv *Server) ServeFiles(path string, opts ServerFilesOptions, middlewares
 		IndexNames:         indexNames,
 		GenerateIndexPages: !opts.DisableDefaultIndexPages,
 		AcceptByteRange:    opts.AcceptByteRange,
-		PathNotFound:       srv.router.NotFound,
+		PathNotFound:       rt.notFound,
 	}
 	if opts.NotFoundHandler != nil {
-		fs.PathNotFound = srv.createEndpointHandler(opts.NotFoundHandler)
+		fs.PathNotFound = rt.srv.createEndpointHandler(nil, opts.NotFoundHandler, nil, nil)
 	}
 
 	// If the url path contains a subdirectory within the base path, we must remove them in order to avoid mapping it
@@ -445,7 +652,7 @@ func (srv *Server) ServeFiles(path string, opts ServerFilesOptions, middlewares
 	}
 
 	// And add to router
-	srv.router.Handle("GET", path, srv.createEndpointHandler(handler, middlewares...))
+	rt.addRoute("GET", path, handler, groupMiddlewares, middlewares)
 
 	// Done
 	return nil
@@ -0,0 +1,132 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+type bindTestInput struct {
+	ID      int           `path:"id" validate:"required,min=1"`
+	Page    *int          `query:"page" validate:"min=1,max=100"`
+	Tags    []string      `query:"tag" validate:"enum=red|green|blue"`
+	Timeout time.Duration `query:"timeout"`
+	Tenant  string        `header:"X-Tenant" validate:"required,regex=^[a-z]+$"`
+	Name    string        `json:"name" validate:"required,max=10"`
+	Count   uint          `json:"count"`
+}
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerBind(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.POST("/items/{id}", func(req *webserver.RequestContext) error {
+			var in bindTestInput
+			var bindErr *webserver.BindError
+
+			err := req.Bind(&in)
+			if err != nil {
+				if errors.As(err, &bindErr) {
+					b, _ := json.Marshal(bindErr)
+					req.Error(string(b), http.StatusBadRequest)
+					return nil
+				}
+				return err
+			}
+			req.WriteJSON(in)
+			return nil
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("X-Tenant", "acme")
+
+	// Send a valid request
+	_, _, body, err := testcommon.QueryPath(http.MethodPost, "/items/12?page=3&tag=red&tag=blue&timeout=5s", headers,
+		strings.NewReader(`{"name":"widget","count":4}`), []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var out bindTestInput
+	err = json.Unmarshal(body, &out)
+	if err != nil {
+		t.Fatalf("unable to decode response [%v]", err)
+	}
+	if out.ID != 12 || out.Page == nil || *out.Page != 3 || len(out.Tags) != 2 || out.Timeout != 5*time.Second ||
+		out.Tenant != "acme" || out.Name != "widget" || out.Count != 4 {
+		t.Fatalf("unexpected bound values [%+v]", out)
+	}
+
+	// Send an invalid request
+	headers.Set("X-Tenant", "ACME")
+	_, _, body, err = testcommon.QueryPath(http.MethodPost, "/items/0?page=abc&tag=pink", headers,
+		strings.NewReader(`{"name":"a very long name"}`), []int{400})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var bindErr webserver.BindError
+	err = json.Unmarshal(body, &bindErr)
+	if err != nil {
+		t.Fatalf("unable to decode response [%v]", err)
+	}
+	failed := make(map[string]string)
+	for _, f := range bindErr.Fields {
+		failed[f.Field] = f.Rule
+	}
+	expected := map[string]string{"id": "min", "page": "", "tag": "enum", "X-Tenant": "regex", "name": "max"}
+	if len(failed) != len(expected) {
+		t.Fatalf("unexpected failed fields [%v]", string(body))
+	}
+	for field, rule := range expected {
+		if r, ok := failed[field]; !ok || r != rule {
+			t.Fatalf("unexpected failed fields [%v]", string(body))
+		}
+	}
+
+	// Body decoding errors are reported along with the rest of the fields
+	_, _, body, err = testcommon.QueryPath(http.MethodPost, "/items/12?page=abc", headers,
+		strings.NewReader(`{"name":"widget","count":-1}`), []int{400})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	bindErr = webserver.BindError{}
+	err = json.Unmarshal(body, &bindErr)
+	if err != nil {
+		t.Fatalf("unable to decode response [%v]", err)
+	}
+	if len(bindErr.Fields) != 3 || bindErr.Fields[0].Field != "count" || bindErr.Fields[0].Source != "body" ||
+		bindErr.Fields[1].Field != "page" || bindErr.Fields[2].Field != "X-Tenant" {
+		t.Fatalf("unexpected failed fields [%v]", string(body))
+	}
+
+	headers.Set("X-Tenant", "acme")
+	_, _, body, err = testcommon.QueryPath(http.MethodPost, "/items/0", headers, strings.NewReader(`{"name":`),
+		[]int{400})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	bindErr = webserver.BindError{}
+	err = json.Unmarshal(body, &bindErr)
+	if err != nil {
+		t.Fatalf("unable to decode response [%v]", err)
+	}
+	if len(bindErr.Fields) != 2 || bindErr.Fields[0].Source != "body" || bindErr.Fields[1].Field != "id" {
+		t.Fatalf("unexpected failed fields [%v]", string(body))
+	}
+}
@@ -0,0 +1,89 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerContinueHandler(t *testing.T) {
+	var handled int32
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address:            "127.0.0.1",
+		Port:               3000,
+		MaxRequestBodySize: 1024,
+		TrustedProxies:     []string{"127.0.0.1"},
+		ContinueHandler: func(req *webserver.RequestContext) error {
+			if req.RequestHeader("X-Api-Key") != "secret" {
+				return webserver.NewHTTPError(http.StatusUnauthorized, "invalid api key")
+			}
+			if req.RemoteIP().String() == "203.0.113.9" {
+				return webserver.NewHTTPError(http.StatusForbidden, "client is banned")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.POST("/upload/{bucket}", func(req *webserver.RequestContext) error {
+		atomic.AddInt32(&handled, 1)
+		_, _ = req.Write(req.PostBody())
+		return nil
+	}).ContinueHandler(func(req *webserver.RequestContext) error {
+		if req.UserValue("bucket") == "locked" {
+			return errors.New("bucket is locked")
+		}
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	query := func(path string, apiKey string, forwardedFor string, body string, expectedStatus int) string {
+		headers := http.Header{}
+		headers.Set("Expect", "100-continue")
+		headers.Set("X-Api-Key", apiKey)
+		if len(forwardedFor) > 0 {
+			headers.Set("X-Forwarded-For", forwardedFor)
+		}
+		_, _, respBody, err2 := testcommon.QueryPath(http.MethodPost, path, headers, strings.NewReader(body), []int{expectedStatus})
+		if err2 != nil {
+			t.Fatalf("%v", err2)
+		}
+		return string(respBody)
+	}
+
+	if query("/upload/docs", "secret", "", "hello", 200) != "hello" {
+		t.Fatalf("unexpected response")
+	}
+	if query("/upload/docs", "secret", "198.51.100.7", "hello", 200) != "hello" {
+		t.Fatalf("unexpected response")
+	}
+
+	// Rejected requests always get a 417 status
+	query("/upload/docs", "wrong", "", "hello", 417)
+	query("/upload/docs", "secret", "203.0.113.9", "hello", 417)
+	query("/upload/locked", "secret", "", "hello", 417)
+
+	// Too large bodies are rejected before reading them
+	query("/upload/docs", "secret", "", strings.Repeat("x", 2048), 413)
+
+	if atomic.LoadInt32(&handled) != 2 {
+		t.Fatalf("handler was called for rejected requests")
+	}
+}
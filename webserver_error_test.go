@@ -0,0 +1,108 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+	"github.com/mxmauro/go-webserver/v2/middleware"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerProblemErrors(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.GET("/out-of-credit", func(req *webserver.RequestContext) error {
+			return &webserver.HTTPError{
+				Status:   http.StatusForbidden,
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "You do not have enough credit.",
+				Detail:   "Your current balance is 30, but that costs 50.",
+				Instance: "/account/12345/msgs/abc",
+				Extensions: map[string]any{
+					"balance": 30,
+					"status":  "ignored",
+				},
+			}
+		})
+		srv.GET("/not-authorized", func(req *webserver.RequestContext) error {
+			return fmt.Errorf("unable to verify token [err=%w]", middleware.ErrNotAuthorized)
+		})
+		srv.GET("/bind", func(req *webserver.RequestContext) error {
+			var in struct {
+				Page int `query:"page" validate:"required"`
+			}
+			return req.Bind(&in)
+		})
+		srv.GET("/failure", func(req *webserver.RequestContext) error {
+			return errors.New("something failed")
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	query := func(path string, accept string, expectedStatus int, expectedContentType string) []byte {
+		headers := http.Header{}
+		if len(accept) > 0 {
+			headers.Set("Accept", accept)
+		}
+		_, respHeaders, body, err := testcommon.QueryPath(http.MethodGet, path, headers, nil, []int{expectedStatus})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.HasPrefix(respHeaders.Get("Content-Type"), expectedContentType) {
+			t.Fatalf("unexpected content type for '%v' [%v]", path, respHeaders.Get("Content-Type"))
+		}
+		return body
+	}
+
+	var problem map[string]any
+
+	body := query("/out-of-credit", "", 403, "application/problem+json")
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Fatalf("unable to decode problem details [%v]", err)
+	}
+	if problem["type"] != "https://example.com/probs/out-of-credit" || problem["title"] != "You do not have enough credit." ||
+		problem["status"] != float64(403) || problem["instance"] != "/account/12345/msgs/abc" ||
+		problem["balance"] != float64(30) {
+		t.Fatalf("unexpected problem details [%v]", string(body))
+	}
+
+	body = query("/out-of-credit", "text/html,application/xhtml+xml,*/*;q=0.8", 403, "text/html")
+	if !strings.Contains(string(body), "<h1>403 You do not have enough credit.</h1>") {
+		t.Fatalf("unexpected html output [%v]", string(body))
+	}
+
+	body = query("/out-of-credit", "text/plain", 403, "text/plain")
+	if string(body) != "Your current balance is 30, but that costs 50." {
+		t.Fatalf("unexpected text output [%v]", string(body))
+	}
+
+	problem = nil
+	body = query("/not-authorized", "application/json", 401, "application/problem+json")
+	if err := json.Unmarshal(body, &problem); err != nil || problem["title"] != "Unauthorized" {
+		t.Fatalf("unexpected problem details [%v]", string(body))
+	}
+
+	problem = nil
+	body = query("/bind", "", 400, "application/problem+json")
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Fatalf("unable to decode problem details [%v]", err)
+	}
+	fields, ok := problem["errors"].([]any)
+	if !ok || len(fields) != 1 || fields[0].(map[string]any)["field"] != "page" {
+		t.Fatalf("unexpected problem details [%v]", string(body))
+	}
+
+	_ = query("/failure", "image/png", 500, "application/problem+json")
+}
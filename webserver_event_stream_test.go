@@ -0,0 +1,168 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerEventStream(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.GET("/events", func(req *webserver.RequestContext) error {
+			req.EventStream(func(es *webserver.EventStream) {
+				_ = es.Send(webserver.Event{
+					ID:   "1",
+					Name: "greeting",
+					Data: "hello\nworld",
+				})
+				_ = es.Comment("note")
+				_ = es.SendData("last=" + es.LastEventID())
+			}, webserver.EventStreamOptions{
+				Retry: 3 * time.Second,
+			})
+			return nil
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("Last-Event-ID", "42")
+	_, respHeaders, body, err := testcommon.QueryPath(http.MethodGet, "/events", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if respHeaders.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type [%v]", respHeaders.Get("Content-Type"))
+	}
+	expected := "retry: 3000\n\nid: 1\nevent: greeting\ndata: hello\ndata: world\n\n: note\n\ndata: last=42\n\n"
+	if string(body) != expected {
+		t.Fatalf("unexpected stream [%q]", string(body))
+	}
+}
+
+func TestWebServerEventHub(t *testing.T) {
+	hub := webserver.NewEventHub(webserver.EventHubOptions{
+		ReplaySize: 10,
+	})
+	defer hub.Close()
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.GET("/news", func(req *webserver.RequestContext) error {
+			hub.Stream(req, []string{"news"})
+			return nil
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	hub.Publish("news", webserver.Event{ID: "1", Data: "one"})
+	hub.Publish("news", webserver.Event{ID: "2", Data: "two"})
+	hub.Publish("news", webserver.Event{ID: "3", Data: "three"})
+
+	// Connect as a client that already received the first event
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:3000/news", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	req.Close = true
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	r := bufio.NewReader(resp.Body)
+
+	readEvent := func() string {
+		lines := make([]string, 0)
+		for {
+			line, err2 := r.ReadString('\n')
+			if err2 != nil {
+				return ""
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if len(line) == 0 {
+				if len(lines) == 0 {
+					continue
+				}
+				return strings.Join(lines, "|")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if ev := readEvent(); ev != "id: 2|data: two" {
+		t.Fatalf("unexpected replayed event [%v]", ev)
+	}
+	if ev := readEvent(); ev != "id: 3|data: three" {
+		t.Fatalf("unexpected replayed event [%v]", ev)
+	}
+
+	if hub.Publish("news", webserver.Event{ID: "4", Name: "update", Data: "four"}) != 1 {
+		t.Fatalf("event was not delivered to the subscriber")
+	}
+	if ev := readEvent(); ev != "id: 4|event: update|data: four" {
+		t.Fatalf("unexpected event [%v]", ev)
+	}
+
+	// Stopping the server must end the stream and remove the subscriber
+	srv.Stop()
+	if ev := readEvent(); ev != "" {
+		t.Fatalf("unexpected event after stop [%v]", ev)
+	}
+	for retry := 0; hub.Subscribers("news") > 0; retry++ {
+		if retry == 50 {
+			t.Fatalf("subscriber was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventHubSlowConsumer(t *testing.T) {
+	hub := webserver.NewEventHub(webserver.EventHubOptions{
+		BufferSize: 1,
+	})
+	defer hub.Close()
+
+	fast := hub.Subscribe("ticks")
+	slow := hub.Subscribe("ticks")
+
+	if hub.Publish("ticks", webserver.Event{Data: "1"}) != 2 {
+		t.Fatalf("event was not delivered to all subscribers")
+	}
+	<-fast.Events()
+
+	// The slow subscriber did not consume the first event so it must be dropped
+	if hub.Publish("ticks", webserver.Event{Data: "2"}) != 1 {
+		t.Fatalf("slow subscriber was not dropped")
+	}
+	if hub.Subscribers("ticks") != 1 {
+		t.Fatalf("unexpected subscribers count")
+	}
+	if ev, ok := <-slow.Events(); !ok || ev.Data != "1" {
+		t.Fatalf("pending events were lost")
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatalf("subscription of the slow consumer is still open")
+	}
+
+	fast.Close()
+	if hub.Subscribers("ticks") != 0 {
+		t.Fatalf("subscription was not removed")
+	}
+}
@@ -0,0 +1,69 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerRouteGroups(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(addTraceMiddleware("server"))
+
+		api := srv.Group("/api/v2", addTraceMiddleware("group"))
+		api.GET("/items", renderTrace, addTraceMiddleware("route"))
+
+		admin := api.Group("admin/", addTraceMiddleware("nested"))
+		admin.GET("/", renderTrace)
+		admin.POST("/users/{id}", renderTrace, addTraceMiddleware("route"))
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	checkTrace := func(method string, path string, expected string) {
+		_, _, body, err := testcommon.QueryPath(method, path, nil, nil, []int{200})
+		if err != nil {
+			t.Fatalf("unable to query %v [%v]", path, err)
+		}
+		if string(body) != expected {
+			t.Fatalf("unexpected middleware chain for %v [got:%v / expected:%v]", path, string(body), expected)
+		}
+	}
+
+	checkTrace(http.MethodGet, "/api/v2/items", "server,group,route")
+	checkTrace(http.MethodGet, "/api/v2/admin", "server,group,nested")
+	checkTrace(http.MethodPost, "/api/v2/admin/users/1", "server,group,nested,route")
+
+	// Routes outside the group must not exist
+	_, _, _, err := testcommon.QueryPath(http.MethodGet, "/items", nil, nil, []int{404})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func addTraceMiddleware(name string) webserver.HandlerFunc {
+	return func(req *webserver.RequestContext) error {
+		trace, _ := req.UserValue("trace").([]string)
+		req.SetUserValue("trace", append(trace, name))
+		return req.Next()
+	}
+}
+
+func renderTrace(req *webserver.RequestContext) error {
+	trace, _ := req.UserValue("trace").([]string)
+	_, _ = req.WriteString(strings.Join(trace, ","))
+	req.Success()
+	return nil
+}
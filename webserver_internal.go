@@ -3,11 +3,24 @@
 package go_webserver
 
 import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+
 	"github.com/valyala/fasthttp"
 )
 
 // -----------------------------------------------------------------------------
 
+var headerRouteCtxPool = sync.Pool{
+	New: func() any {
+		return &fasthttp.RequestCtx{}
+	},
+}
+
+// -----------------------------------------------------------------------------
+
 func (srv *Server) createMainHandler() fasthttp.RequestHandler {
 	// Wrapper
 	return func(ctx *fasthttp.RequestCtx) {
@@ -22,12 +35,75 @@ func (srv *Server) createMainHandler() fasthttp.RequestHandler {
 	}
 }
 
-func (srv *Server) createEndpointHandler(h HandlerFunc, middlewares ...HandlerFunc) fasthttp.RequestHandler {
+func (srv *Server) createEndpointHandler(r *Route, h HandlerFunc, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc) fasthttp.RequestHandler {
 	// Wrapper
 	return func(ctx *fasthttp.RequestCtx) {
 		req, ok := ctx.UserValue(reqContextLinkKey).(*RequestContext)
 		if ok {
-			req.setHandlerParams(h, middlewares)
+			req.setHandlerParams(r, h, groupMiddlewares, middlewares)
+		}
+	}
+}
+
+// onHeaderReceived applies the maximum request body size of the route that will handle the request, if it
+// overrides the server's one.
+func (srv *Server) onHeaderReceived(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	ctx := headerRouteCtxPool.Get().(*fasthttp.RequestCtx)
+	defer func() {
+		ctx.ResetUserValues()
+		headerRouteCtxPool.Put(ctx)
+	}()
+
+	// Select the virtual host like the router does, so the forwarded host is used if sent by a trusted proxy
+	host := header.Host()
+	if srv.hosts.Load() != nil {
+		if fwdHost := forwardedHost(header); len(fwdHost) > 0 && srv.isProxyTrusted(srv.conns.current()) {
+			host = fwdHost
 		}
 	}
+
+	rt := srv.selectRouteTableByHost(string(host))
+	hr := rt.findHeaderRoute(header.Method(), header.RequestURI(), ctx)
+	if hr == nil {
+		return fasthttp.RequestConfig{}
+	}
+
+	// Done
+	return fasthttp.RequestConfig{
+		MaxRequestBodySize: hr.maxBodySize,
+	}
+}
+
+// isProxyTrusted checks if the peer of the given connection is a trusted proxy like RequestContext does.
+func (srv *Server) isProxyTrusted(conn net.Conn) bool {
+	if srv.trustedProxy == nil {
+		return true
+	}
+	return srv.trustedProxy.IsIpTrusted(remoteIP(conn))
+}
+
+// onParseError sends the response for requests that cannot be read. It behaves like fasthttp's default handler
+// but reports bodies exceeding the maximum size with a 413 status.
+func onParseError(ctx *fasthttp.RequestCtx, err error) {
+	var smallBufErr *fasthttp.ErrSmallBuffer
+	var netErr *net.OpError
+
+	switch {
+	case errors.Is(err, fasthttp.ErrBodyTooLarge):
+		ctx.Error("Request body too large", fasthttp.StatusRequestEntityTooLarge)
+	case errors.As(err, &smallBufErr):
+		ctx.Error("Too big request header", fasthttp.StatusRequestHeaderFieldsTooLarge)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		ctx.Error("Request timeout", fasthttp.StatusRequestTimeout)
+	default:
+		ctx.Error("Error when parsing request", fasthttp.StatusBadRequest)
+	}
+}
+
+func defaultRequestErrorHandler(req *RequestContext, err error) {
+	httpErr := ToHTTPError(err)
+	if httpErr.Status >= 500 {
+		req.Log().Error("unable to process request", slog.Int("status", httpErr.Status), slog.Any("error", err))
+	}
+	req.SendError(err)
 }
@@ -0,0 +1,78 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+type createItemInput struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type createItemOutput struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerJSONHandler(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.POST("/items", webserver.JSONHandler(
+			func(_ context.Context, _ *webserver.RequestContext, in createItemInput) (createItemOutput, error) {
+				if in.Name == "duplicated" {
+					return createItemOutput{}, webserver.NewHTTPError(http.StatusConflict, "item already exists")
+				}
+				return createItemOutput{ID: 1, Name: in.Name}, nil
+			},
+			webserver.JSONHandlerOptions{
+				SuccessStatus: http.StatusCreated,
+				MaxBodySize:   64,
+			},
+		))
+		srv.POST("/sum", webserver.JSONHandler(
+			func(_ context.Context, _ *webserver.RequestContext, in []int) (int, error) {
+				total := 0
+				for _, v := range in {
+					total += v
+				}
+				return total, nil
+			},
+		))
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	query := func(path string, contentType string, body string, expectedStatus int, expectedBody string) {
+		headers := http.Header{}
+		headers.Set("Content-Type", contentType)
+		_, _, respBody, err := testcommon.QueryPath(http.MethodPost, path, headers, strings.NewReader(body),
+			[]int{expectedStatus})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(expectedBody) > 0 && strings.TrimSpace(string(respBody)) != expectedBody {
+			t.Fatalf("unexpected response [got:%v / expected:%v]", string(respBody), expectedBody)
+		}
+	}
+
+	query("/items", "application/json", `{"name":"widget"}`, 201, `{"id":1,"name":"widget"}`)
+	query("/items", "application/json", `{"name":"duplicated"}`, 409, `{"detail":"item already exists","status":409,"title":"Conflict","type":"about:blank"}`)
+	query("/items", "application/json", `{"name":`, 400, "")
+	query("/items", "application/json", `{}`, 400, "")
+	query("/items", "text/plain", `widget`, 415, "")
+	query("/items", "application/json", `{"name":"`+strings.Repeat("x", 64)+`"}`, 413, "")
+	query("/sum", "application/json", `[1,2,3]`, 200, "6")
+}
@@ -0,0 +1,151 @@
+// See the LICENSE file for license details.
+
+//go:build unix
+
+package go_webserver_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerMultipleListeners(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "webserver.sock")
+
+	// Leave a stale socket file like a crashed instance would do
+	staleLn, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("unable to create listener [%v]", err)
+	}
+	staleLn.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = staleLn.Close()
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		ListenEndpoints: []webserver.ListenEndpoint{
+			{
+				Address:  "unix:" + socketPath,
+				FileMode: 0o660,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/ping", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString("pong")
+		req.Success()
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	// Check the socket file permissions
+	fi, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("unable to stat unix socket [%v]", err)
+	}
+	if fi.Mode().Perm() != 0o660 {
+		t.Fatalf("unexpected unix socket permissions [got:%v / expected:%v]", fi.Mode().Perm(), os.FileMode(0o660))
+	}
+
+	// Query through TCP
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/ping", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("unable to query tcp endpoint [%v]", err)
+	}
+	if string(body) != "pong" {
+		t.Fatalf("unexpected tcp response [%v]", string(body))
+	}
+
+	// Query through the Unix domain socket
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	resp, err := client.Get("http://localhost/ping")
+	if err != nil {
+		t.Fatalf("unable to query unix socket endpoint [%v]", err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(body) != "pong" {
+		t.Fatalf("unexpected unix socket response [%v] [%v]", string(body), err)
+	}
+
+	// A socket in use must not be taken over by another server
+	srv2, err := webserver.Create(webserver.Options{
+		ListenEndpoints: []webserver.ListenEndpoint{
+			{
+				Address: "unix:" + socketPath,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	if srv2.Start() == nil {
+		srv2.Stop()
+		t.Fatalf("server started on a unix socket in use")
+	}
+	if _, err = os.Stat(socketPath); err != nil {
+		t.Fatalf("unix socket in use was removed [%v]", err)
+	}
+}
+
+func TestWebServerServeListener(t *testing.T) {
+	//Create server without endpoints
+	srv, err := webserver.Create(webserver.Options{})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/ping", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString("pong")
+		req.Success()
+		return nil
+	})
+
+	// Start must fail because there is nothing to listen on
+	if srv.Start() == nil {
+		t.Fatalf("server started without listen endpoints")
+	}
+
+	// Use an already opened listener
+	ln, err := net.Listen("tcp4", "127.0.0.1:3000")
+	if err != nil {
+		t.Fatalf("unable to create listener [%v]", err)
+	}
+	err = srv.Serve(ln)
+	if err != nil {
+		_ = ln.Close()
+		t.Fatalf("unable to serve on listener [%v]", err)
+	}
+	defer srv.Stop()
+
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/ping", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("unable to query listener [%v]", err)
+	}
+	if string(body) != "pong" {
+		t.Fatalf("unexpected response [%v]", string(body))
+	}
+}
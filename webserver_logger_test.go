@@ -0,0 +1,133 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+type logBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerLogger(t *testing.T) {
+	output := &logBuffer{}
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		Logger:  slog.New(slog.NewJSONHandler(output, nil)),
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.Use(func(req *webserver.RequestContext) error {
+		req.SetRequestID(req.RequestHeader("X-Request-ID"), "X-Request-ID")
+		return req.Next()
+	})
+	srv.GET("/hello", func(req *webserver.RequestContext) error {
+		req.Log().Info("saying hello")
+		req.Success()
+		return nil
+	})
+	srv.GET("/fail", func(req *webserver.RequestContext) error {
+		return errors.New("database is down")
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("X-Request-ID", "req-1")
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/hello", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/fail", nil, nil, []int{500})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Send a malformed request so fasthttp reports it
+	conn, err := net.Dial("tcp", "127.0.0.1:3000")
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: 127.0.0.1\r\nContent-Length: abc\r\n\r\n"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Read(make([]byte, 1024))
+	_ = conn.Close()
+
+	// Give the server some time to log the error
+	var records []map[string]any
+	for retry := 0; retry < 20; retry++ {
+		records = output.records(t)
+		if len(records) >= 3 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(records) != 3 {
+		t.Fatalf("unexpected number of records [%d]", len(records))
+	}
+
+	if records[0]["msg"] != "saying hello" || records[0]["request_id"] != "req-1" || records[0]["method"] != "GET" ||
+		records[0]["path"] != "/hello" || records[0]["remote_ip"] != "127.0.0.1" {
+		t.Fatalf("unexpected request record [%v]", records[0])
+	}
+	if records[1]["level"] != "ERROR" || records[1]["path"] != "/fail" || records[1]["error"] != "database is down" {
+		t.Fatalf("unexpected error record [%v]", records[1])
+	}
+	if records[2]["level"] != "WARN" || records[2]["component"] != "fasthttp" ||
+		!strings.Contains(records[2]["msg"].(string), "error when serving connection") {
+		t.Fatalf("unexpected fasthttp record [%v]", records[2])
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) records(t *testing.T) []map[string]any {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	records := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unable to decode record [%v]", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
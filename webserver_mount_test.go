@@ -0,0 +1,100 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"net/http"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerMount(t *testing.T) {
+	// Create the sub-server
+	sub, err := webserver.Create(webserver.Options{})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	sub.Use(addTraceMiddleware("sub"))
+	sub.GET("/items/{id}", renderTrace, addTraceMiddleware("route")).Name("item")
+	sub.GET("/id", func(req *webserver.RequestContext) error {
+		req.SetRequestID("sub-1", "X-Request-ID")
+		req.Success()
+		return nil
+	})
+	sub.GET("/link", func(req *webserver.RequestContext) error {
+		url, err2 := req.URLFor("item", "id", "2")
+		if err2 != nil {
+			return err2
+		}
+		_, _ = req.WriteString(url)
+		return nil
+	})
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(addTraceMiddleware("server"))
+		srv.Use(func(req *webserver.RequestContext) error {
+			err2 := req.Next()
+			req.SetResponseHeader("X-Parent-Request-ID", req.RequestID())
+			return err2
+		})
+		srv.Mount("/module", sub)
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/module/items/1", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "server,sub,route" {
+		t.Fatalf("unexpected middleware chain [%v]", string(body))
+	}
+
+	_, _, body, err = testcommon.QueryPath(http.MethodGet, "/module/link", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "/module/items/2" {
+		t.Fatalf("unexpected url [%v]", string(body))
+	}
+
+	// The request id set by the sub-server must be visible to the parent's middlewares
+	_, headers, _, err := testcommon.QueryPath(http.MethodGet, "/module/id", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if headers.Get("X-Parent-Request-ID") != "sub-1" {
+		t.Fatalf("unexpected request id [%v]", headers.Get("X-Parent-Request-ID"))
+	}
+
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/module/missing", nil, nil, []int{404})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Redirections generated by the sub-server's router must keep the prefix
+	client := http.Client{
+		// Do not leave a pooled connection to a server that is stopped when the test ends
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get("http://127.0.0.1:3000/module/items/1/")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "http://127.0.0.1:3000/module/items/1" {
+		t.Fatalf("unexpected redirection [status:%v / location:%v]", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
@@ -0,0 +1,172 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerMultipartReader(t *testing.T) {
+	tempDir := t.TempDir()
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address:                      "127.0.0.1",
+		Port:                         3000,
+		MaxRequestBodySize:           1024,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.POST("/upload", func(req *webserver.RequestContext) error {
+		mr, err := req.MultipartReader(webserver.MultipartReaderOptions{
+			MaxPartSize: 256 * 1024,
+			TempDir:     tempDir,
+			DiskQuota:   512 * 1024,
+		})
+		if err != nil {
+			return err
+		}
+		defer mr.Close()
+
+		sb := strings.Builder{}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if len(part.FileName()) == 0 {
+				value, err := io.ReadAll(part)
+				if err != nil {
+					return err
+				}
+				_, _ = sb.WriteString(fmt.Sprintf("%s=%s;", part.FormName(), value))
+			} else {
+				sf, err := part.Spool()
+				if err != nil {
+					return err
+				}
+				info, err := os.Stat(sf.Path)
+				if err != nil || info.Size() != sf.Size {
+					return errors.New("invalid spooled file")
+				}
+				_, _ = sb.WriteString(fmt.Sprintf("%s:%s=%d;", sf.FormName, sf.FileName, sf.Size))
+			}
+		}
+		req.SetResponseHeader("Content-Type", "text/plain")
+		_, _ = req.WriteString(sb.String())
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	buildBody := func(fileSizes ...int) (http.Header, io.Reader) {
+		body := bytes.Buffer{}
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("name", "john")
+		for idx, size := range fileSizes {
+			fw, _ := w.CreateFormFile("file", fmt.Sprintf("file%d.bin", idx+1))
+			_, _ = fw.Write(bytes.Repeat([]byte{'x'}, size))
+		}
+		_ = w.Close()
+
+		headers := http.Header{}
+		headers.Set("Content-Type", w.FormDataContentType())
+		return headers, &body
+	}
+
+	// Bodies larger than the server limit must be streamed to the handler
+	headers, body := buildBody(200*1024, 100*1024)
+	_, _, respBody, err := testcommon.QueryPath(http.MethodPost, "/upload", headers, body, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(respBody) != "name=john;file:file1.bin=204800;file:file2.bin=102400;" {
+		t.Fatalf("unexpected response [%v]", string(respBody))
+	}
+
+	// Per-part limit
+	headers, body = buildBody(300 * 1024)
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/upload", headers, body, []int{413})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Disk quota
+	headers, body = buildBody(200*1024, 200*1024, 200*1024)
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/upload", headers, body, []int{413})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Non-multipart bodies
+	headers = http.Header{}
+	headers.Set("Content-Type", "text/plain")
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/upload", headers, strings.NewReader("hello"), []int{415})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Spooled files must be removed
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("unable to read temporary directory [%v]", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("spooled files were not removed")
+	}
+}
+
+func TestWebServerRouteBodyLimit(t *testing.T) {
+	echoSize := func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString(fmt.Sprintf("%d", len(req.PostBody())))
+		return nil
+	}
+
+	tws := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.POST("/small", echoSize).MaxRequestBodySize(16)
+		srv.POST("/large/{id}", echoSize).MaxRequestBodySize(8 * 1048576)
+		return nil
+	})
+	defer tws.Stop()
+
+	_, _, _, err := testcommon.QueryPath(http.MethodPost, "/small", nil, strings.NewReader(strings.Repeat("x", 1024)), []int{413})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/small", nil, strings.NewReader("hello"), []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	largeBody := strings.Repeat("x", 5*1048576)
+	_, _, respBody, err := testcommon.QueryPath(http.MethodPost, "/large/1", nil, strings.NewReader(largeBody), []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(respBody) != "5242880" {
+		t.Fatalf("unexpected response [%v]", string(respBody))
+	}
+}
@@ -0,0 +1,76 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/proxy_protocol"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerProxyProtocol(t *testing.T) {
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address:     "127.0.0.1",
+		Port:        3000,
+		ReadTimeout: 300 * time.Millisecond,
+		ProxyProtocol: &proxy_protocol.Options{
+			TrustedSources: []string{"127.0.0.1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/ip", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString(req.RemoteIP().String())
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	proxyHeader := "PROXY TCP4 192.0.2.10 127.0.0.1 56324 3000\r\n"
+
+	// The client address is taken from the header
+	resp := sendRawRequest(t, proxyHeader+"GET /ip HTTP/1.1\r\nHost: 127.0.0.1\r\nConnection: close\r\n\r\n")
+	if !strings.HasPrefix(resp, "HTTP/1.1 200") || !strings.HasSuffix(resp, "192.0.2.10") {
+		t.Fatalf("unexpected response [%v]", resp)
+	}
+
+	// A slow first request must not outlive the read timeout
+	start := time.Now()
+	_ = sendRawRequest(t, proxyHeader+"GET /ip HTTP/1.1\r\n")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("slow request was not timed out [%v]", elapsed)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func sendRawRequest(t *testing.T, request string) string {
+	conn, err := net.Dial("tcp", "127.0.0.1:3000")
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err = conn.Write([]byte(request))
+	if err != nil {
+		t.Fatalf("unable to send request [%v]", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	resp, _ := io.ReadAll(conn)
+	return string(resp)
+}
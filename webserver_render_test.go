@@ -0,0 +1,108 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// -----------------------------------------------------------------------------
+
+type renderTestItem struct {
+	ID   int    `json:"id" xml:"id" msgpack:"id" cbor:"id"`
+	Name string `json:"name" xml:"name" msgpack:"name" cbor:"name"`
+}
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerRender(t *testing.T) {
+	webserver.RegisterEncoder("text/csv", func(w io.Writer, v any) error {
+		for _, item := range v.([]renderTestItem) {
+			_, err := fmt.Fprintf(w, "%d,%s\n", item.ID, item.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		webserver.UnregisterEncoder("text/csv")
+	})
+
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.GET("/items", func(req *webserver.RequestContext) error {
+			return req.Render(http.StatusCreated, []renderTestItem{{ID: 1, Name: "one"}, {ID: 2, Name: "two"}})
+		})
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	query := func(accept string, expectedStatus int, expectedContentType string) []byte {
+		headers := http.Header{}
+		if len(accept) > 0 {
+			headers.Set("Accept", accept)
+		}
+		_, respHeaders, body, err := testcommon.QueryPath(http.MethodGet, "/items", headers, nil, []int{expectedStatus})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(expectedContentType) > 0 && !strings.HasPrefix(respHeaders.Get("Content-Type"), expectedContentType) {
+			t.Fatalf("unexpected content type for '%v' [%v]", accept, respHeaders.Get("Content-Type"))
+		}
+		return body
+	}
+
+	body := query("", 201, "application/json")
+	if strings.TrimSpace(string(body)) != `[{"id":1,"name":"one"},{"id":2,"name":"two"}]` {
+		t.Fatalf("unexpected json output [%v]", string(body))
+	}
+
+	body = query("text/html, application/xml;q=0.9", 201, "application/xml")
+	if !strings.Contains(string(body), "<renderTestItem><id>1</id><name>one</name></renderTestItem>") {
+		t.Fatalf("unexpected xml output [%v]", string(body))
+	}
+
+	var items []renderTestItem
+	body = query("application/msgpack", 201, "application/msgpack")
+	if err := msgpack.Unmarshal(body, &items); err != nil || len(items) != 2 || items[1].Name != "two" {
+		t.Fatalf("unexpected msgpack output [%v]", err)
+	}
+
+	items = nil
+	body = query("application/json;q=0.5, application/cbor;q=0.9", 201, "application/cbor")
+	if err := cbor.Unmarshal(body, &items); err != nil || len(items) != 2 || items[0].ID != 1 {
+		t.Fatalf("unexpected cbor output [%v]", err)
+	}
+
+	body = query("application/x-ndjson", 201, "application/x-ndjson")
+	if string(body) != "{\"id\":1,\"name\":\"one\"}\n{\"id\":2,\"name\":\"two\"}\n" {
+		t.Fatalf("unexpected ndjson output [%v]", string(body))
+	}
+
+	body = query("text/*", 201, "text/plain")
+	if string(body) != "[{1 one} {2 two}]" {
+		t.Fatalf("unexpected text output [%v]", string(body))
+	}
+
+	body = query("text/csv", 201, "text/csv")
+	if string(body) != "1,one\n2,two\n" {
+		t.Fatalf("unexpected csv output [%v]", string(body))
+	}
+
+	// Explicitly rejected types must not be selected by wildcards
+	_ = query("*/*;q=0.1, application/json;q=0", 201, "application/xml")
+
+	_ = query("image/png", 406, "")
+}
@@ -0,0 +1,179 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerNamedRoutes(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.GET("/users/{id:[0-9]+}", renderAbsoluteUserUrl).Name("user")
+		srv.GET("/docs/{lang?}/index", renderAbsoluteUserUrl).Name("docs")
+		srv.Group("/static").GET("/{filepath:*}", renderAbsoluteUserUrl).Name("static")
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	checkUrl := func(expected string, name string, params ...string) {
+		url, err := srv.Server.URL(name, params...)
+		if err != nil {
+			t.Fatalf("unable to build url for route %v [%v]", name, err)
+		}
+		if url != expected {
+			t.Fatalf("unexpected url for route %v [got:%v / expected:%v]", name, url, expected)
+		}
+	}
+
+	checkUrl("/users/10", "user", "id", "10")
+	checkUrl("/users/10?page=2", "user", "id", "10", "page", "2")
+	checkUrl("/docs/en/index", "docs", "lang", "en")
+	checkUrl("/docs/index", "docs")
+	checkUrl("/static/css/site%20main.css", "static", "filepath", "css/site main.css")
+
+	_, err := srv.Server.URL("user", "id", "abc")
+	if err == nil {
+		t.Fatalf("url built with a parameter not matching its constraint")
+	}
+	_, err = srv.Server.URL("user")
+	if err == nil {
+		t.Fatalf("url built with a missing parameter")
+	}
+
+	// Check absolute urls
+	headers := http.Header{}
+	headers.Set("X-Forwarded-Proto", "https")
+	headers.Set("X-Forwarded-Host", "www.example.com")
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/users/1", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "https://www.example.com/users/5" {
+		t.Fatalf("unexpected absolute url [%v]", string(body))
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func renderAbsoluteUserUrl(req *webserver.RequestContext) error {
+	url, err := req.AbsoluteURLFor("user", "id", "5")
+	if err != nil {
+		return err
+	}
+	_, _ = req.WriteString(url)
+	return nil
+}
+
+func TestWebServerRouteTable(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		admin := srv.Group("/admin", addTraceMiddleware("group"))
+		admin.DELETE("/users/{id}", renderTrace, addTraceMiddleware("route")).
+			Name("delete-user").
+			Description("Deletes a user").
+			Tags("users", "admin")
+		admin.ServeRouteTable("/routes")
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	var deleteRoute *webserver.RouteInfo
+
+	routes := srv.Server.Routes()
+	for idx := range routes {
+		if routes[idx].Method == http.MethodDelete && routes[idx].Path == "/admin/users/{id}" {
+			deleteRoute = &routes[idx]
+		}
+	}
+	if deleteRoute == nil {
+		t.Fatalf("route not found in the route table")
+	}
+	if deleteRoute.Name != "delete-user" || deleteRoute.Description != "Deletes a user" ||
+		len(deleteRoute.Tags) != 2 || deleteRoute.MiddlewareCount != 2 ||
+		!strings.HasSuffix(deleteRoute.Handler, ".renderTrace") {
+		t.Fatalf("unexpected route information [%+v]", *deleteRoute)
+	}
+
+	// Check the admin endpoint
+	headers := http.Header{}
+	headers.Set("Accept", "application/json")
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/admin/routes", headers, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.Contains(string(body), `"path":"/admin/users/{id}"`) {
+		t.Fatalf("route not found in the admin endpoint output [%v]", string(body))
+	}
+}
+
+func TestWebServerRuntimeRoutes(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		return nil
+	})
+	defer srv.Stop()
+
+	// Keep sending requests while the route table changes
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+				_, _, _, _ = testcommon.QueryPath(http.MethodGet, "/plugin/hello", nil, nil, []int{200, 404})
+			}
+		}
+	}()
+
+	for round := 0; round < 10; round++ {
+		r := srv.Server.GET("/plugin/hello", renderTrace, addTraceMiddleware("route"))
+
+		_, _, body, err := testcommon.QueryPath(http.MethodGet, "/plugin/hello", nil, nil, []int{200})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if round == 0 && string(body) != "route" {
+			t.Fatalf("unexpected middleware chain [%v]", string(body))
+		}
+
+		if !r.Remove() {
+			t.Fatalf("unable to remove route")
+		}
+		_, _, _, err = testcommon.QueryPath(http.MethodGet, "/plugin/hello", nil, nil, []int{404})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	close(stopCh)
+	<-doneCh
+
+	// Add a server middleware and a route to a running server
+	srv.Server.Use(addTraceMiddleware("server"))
+	srv.Server.GET("/plugin/hello", renderTrace)
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/plugin/hello", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "server" {
+		t.Fatalf("unexpected middleware chain [%v]", string(body))
+	}
+	if !srv.Server.RemoveRoute(http.MethodGet, "/plugin/hello") {
+		t.Fatalf("unable to remove route")
+	}
+}
@@ -4,9 +4,9 @@ package go_webserver
 
 import (
 	"context"
+	"log/slog"
 	"net"
 	"sync/atomic"
-	"time"
 )
 
 // -----------------------------------------------------------------------------
@@ -19,22 +19,33 @@ const (
 	stateStopped    = 5
 )
 
-const (
-	shutdownTimeout = 5 * time.Second
-)
+func (srv *Server) serve(lns []net.Listener) {
+	ch := make(chan error, len(lns))
 
-// -----------------------------------------------------------------------------
+	// From now on, changes to the route table must not modify the router being used to serve requests
+	srv.setRoutesLive()
 
-func (srv *Server) serve(ln net.Listener) {
-	ch := make(chan error, 1)
+	// Keep track of the raw listeners in order to be able to hand them off on upgrades
+	srv.listeners = lns
+	srv.listenerNames = make([]string, len(lns))
+	for idx, ln := range lns {
+		srv.listenerNames[idx] = listenerName(ln)
+	}
 
-	go func(ln net.Listener) {
-		ch <- srv.fastserver.Serve(ln)
-	}(ln)
+	lns = srv.wrapListeners(lns)
+
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			ch <- srv.fastserver.Serve(ln)
+		}(ln)
+	}
 
 	// Set new state
 	srv.setState(stateRunning)
 
+	// Watch for upgrade requests. Do it before serveLoop runs so it cannot stop watching them in the meantime.
+	srv.watchUpgradeSignal()
+
 	// Run in background until shutdown or error
 	go srv.serveLoop(ch)
 }
@@ -45,21 +56,36 @@ func (srv *Server) serveLoop(ch chan error) {
 		srv.setState(stateStopping)
 
 		// Web server is no longer able to accept more connections
-		if srv.listenErrorHandler != nil && err != nil {
-			srv.listenErrorHandler(srv, err)
+		if err != nil {
+			srv.logger.Error("unable to accept connections", slog.Any("error", err))
+			if srv.listenErrorHandler != nil {
+				srv.listenErrorHandler(srv, err)
+			}
 		}
 
+		// Shut down the rest of the listeners, if any
+		ctx, ctxCancel := context.WithTimeout(context.Background(), srv.shutdownTimeout)
+		srv.shutdownErr = srv.fastserver.ShutdownWithContext(ctx)
+		srv.webSockets.closeAll(ctx)
+		ctxCancel()
+
 	// handle termination signal
-	case <-srv.startShutdownSignal:
+	case ctx := <-srv.startShutdownSignal:
 		srv.setState(stateStopping)
 
 		// Attempt the graceful shutdown by closing the listener and completing all inflight requests.
-		ctx, ctxCancel := context.WithTimeout(context.Background(), shutdownTimeout)
-		_ = srv.fastserver.ShutdownWithContext(ctx)
-		ctxCancel()
+		srv.shutdownErr = srv.fastserver.ShutdownWithContext(ctx)
+
+		// Hijacked connections are not tracked by the underlying server
+		srv.webSockets.closeAll(ctx)
 	}
 
+	srv.stopWatchingUpgradeSignal()
+
 	srv.setState(stateStopped)
+
+	// Wake up waiters
+	close(srv.shutdownCompleteSignal)
 }
 
 func (srv *Server) setState(newState int32) {
@@ -0,0 +1,86 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerStopWithContext(t *testing.T) {
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address:             "127.0.0.1",
+		Port:                3000,
+		ShutdownGracePeriod: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	requestStarted := make(chan struct{})
+	srv.GET("/slow", func(req *webserver.RequestContext) error {
+		close(requestStarted)
+		time.Sleep(2 * time.Second)
+		req.Success()
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	if !srv.IsReady() {
+		t.Fatalf("server is not ready after start")
+	}
+
+	// Send a slow request in background
+	requestDone := make(chan struct{})
+	go func() {
+		_, _, _, _ = testcommon.QueryPath(http.MethodGet, "/slow", nil, nil, []int{200})
+		close(requestDone)
+	}()
+	<-requestStarted
+
+	waitDone := make(chan struct{})
+	go func() {
+		srv.Wait()
+		close(waitDone)
+	}()
+
+	// Stop the server but do not wait enough for the slow request to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	stopDone := make(chan error, 1)
+	go func() {
+		stopDone <- srv.StopWithContext(ctx)
+	}()
+
+	// During the grace period, the server must report it is not ready
+	time.Sleep(50 * time.Millisecond)
+	if srv.IsReady() {
+		t.Fatalf("server is ready while shutting down")
+	}
+
+	err = <-stopDone
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected shutdown result [%v]", err)
+	}
+
+	select {
+	case <-waitDone:
+	case <-time.After(time.Second):
+		t.Fatalf("Wait did not return after the server was stopped")
+	}
+
+	// Do not let the slow request run into the next test
+	<-requestDone
+}
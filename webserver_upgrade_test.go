@@ -0,0 +1,177 @@
+// See the LICENSE file for license details.
+
+//go:build unix
+
+package go_webserver_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerUpgrade(t *testing.T) {
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		ListenEndpoints: []webserver.ListenEndpoint{
+			{
+				Address: "127.0.0.1:3001",
+			},
+			{
+				Address: "127.0.0.1:3002",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.GET("/whoami", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString("old")
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	_, _, body, err := testcommon.QueryPath(http.MethodGet, "/whoami", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "old" {
+		t.Fatalf("unexpected response [%v]", string(body))
+	}
+
+	// Hand off the listeners to a new instance of the test binary that only runs TestWebServerUpgradeChild
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestWebServerUpgradeChild$"}
+	err = srv.Upgrade()
+	os.Args = args
+	if err != nil {
+		t.Fatalf("unable to upgrade [%v]", err)
+	}
+	if srv.IsReady() {
+		t.Fatalf("server is ready after the upgrade")
+	}
+
+	// The servers of the new process must serve the inherited sockets and the one nobody uses must be closed
+	_, _, body, err = testcommon.QueryPath(http.MethodGet, "/whoami", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "new" {
+		t.Fatalf("unexpected response [%v]", string(body))
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+	}
+	resp, err := client.Get("http://127.0.0.1:3001/whoami")
+	if err != nil {
+		t.Fatalf("unable to query the second server [%v]", err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(body) != "new-metrics" {
+		t.Fatalf("unexpected response of the second server [%v] [%v]", string(body), err)
+	}
+	conn, err := net.Dial("tcp", "127.0.0.1:3002")
+	if err == nil {
+		_ = conn.Close()
+		t.Fatalf("unclaimed listener was not closed")
+	}
+
+	// Stop the new process and wait until the socket is released
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/quit", nil, nil, []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for retry := 0; ; retry++ {
+		conn, err = net.Dial("tcp", "127.0.0.1:3000")
+		if err != nil {
+			break
+		}
+		_ = conn.Close()
+		if retry >= 50 {
+			t.Fatalf("the new process did not stop")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// TestWebServerUpgradeChild runs in the process created by TestWebServerUpgrade.
+func TestWebServerUpgradeChild(t *testing.T) {
+	if len(os.Getenv("GO_WEBSERVER_UPGRADE_LISTENERS")) == 0 {
+		t.Skip("not running as the new process of an upgrade")
+	}
+
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	quit := make(chan struct{})
+	srv.GET("/whoami", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString("new")
+		return nil
+	})
+	srv.GET("/quit", func(req *webserver.RequestContext) error {
+		close(quit)
+		req.Success()
+		return nil
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+
+	// A second server, like a metrics one, also reuses its inherited socket. Take a while to start it so the parent
+	// would find it missing if it was notified before calling UpgradeReady.
+	time.Sleep(300 * time.Millisecond)
+	metricsSrv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3001,
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	metricsSrv.GET("/whoami", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString("new-metrics")
+		return nil
+	})
+
+	err = metricsSrv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+
+	// Let the parent know once all the servers are running
+	webserver.UpgradeReady()
+
+	select {
+	case <-quit:
+	case <-time.After(10 * time.Second):
+	}
+	metricsSrv.Stop()
+	srv.Stop()
+
+	// Exit now so the output of the parent's test run is not mixed with ours
+	os.Exit(0)
+}
@@ -0,0 +1,102 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerVirtualHosts(t *testing.T) {
+	//Create server
+	srv := testcommon.RunWebServer(t, func(srv *webserver.Server) error {
+		srv.Use(addTraceMiddleware("server"))
+		srv.GET("/whoami", renderTrace, addTraceMiddleware("default"))
+
+		api := srv.Host("api.example.com")
+		api.Use(addTraceMiddleware("api"))
+		api.GET("/whoami", renderTrace)
+		api.NotFound(func(req *webserver.RequestContext) error {
+			req.Error("api not found", http.StatusNotFound)
+			return nil
+		})
+
+		tenants := srv.Host("*.tenants.example.com")
+		tenants.Group("/v1", addTraceMiddleware("group")).GET("/whoami", renderTrace)
+
+		// Done
+		return nil
+	})
+	defer srv.Stop()
+
+	checkHost := func(host string, path string, expectedStatus int, expected string) {
+		headers := http.Header{}
+		headers.Set("X-Forwarded-Host", host)
+		_, _, body, err := testcommon.QueryPath(http.MethodGet, path, headers, nil, []int{expectedStatus})
+		if err != nil {
+			t.Fatalf("unable to query %v%v [%v]", host, path, err)
+		}
+		if string(body) != expected {
+			t.Fatalf("unexpected response for %v%v [got:%v / expected:%v]", host, path, string(body), expected)
+		}
+	}
+
+	checkHost("api.example.com", "/whoami", 200, "server,api")
+	checkHost("API.example.com:8080", "/whoami", 200, "server,api")
+	checkHost("api.example.com", "/missing", 404, "api not found")
+	checkHost("acme.tenants.example.com", "/v1/whoami", 200, "server,group")
+	checkHost("tenants.example.com", "/whoami", 200, "server,default")
+	checkHost("www.example.com", "/whoami", 200, "server,default")
+
+	// Routes of a virtual host must not be reachable from other hosts
+	checkHost("www.example.com", "/v1/whoami", 404, "Not Found")
+}
+
+func TestWebServerVirtualHostsBodySize(t *testing.T) {
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address:            "127.0.0.1",
+		Port:               3000,
+		MaxRequestBodySize: 1024,
+		TrustedProxies:     []string{"127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.Host("api.example.com").POST("/upload", func(req *webserver.RequestContext) error {
+		_, _ = req.WriteString(strconv.Itoa(len(req.PostBody())))
+		return nil
+	}).MaxRequestBodySize(4096)
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	// The limit of the virtual host's route applies when the host is forwarded by a trusted proxy
+	headers := http.Header{}
+	headers.Set("X-Forwarded-Host", "api.example.com")
+	_, _, body, err := testcommon.QueryPath(http.MethodPost, "/upload", headers,
+		strings.NewReader(strings.Repeat("x", 2048)), []int{200})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(body) != "2048" {
+		t.Fatalf("unexpected response [%v]", string(body))
+	}
+
+	// Else the server's limit applies
+	_, _, _, err = testcommon.QueryPath(http.MethodPost, "/upload", nil, strings.NewReader(strings.Repeat("x", 2048)),
+		[]int{413})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+}
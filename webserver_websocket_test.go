@@ -0,0 +1,161 @@
+// See the LICENSE file for license details.
+
+package go_webserver_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	webserver "github.com/mxmauro/go-webserver/v2"
+	"github.com/mxmauro/go-webserver/v2/internal/testcommon"
+)
+
+// -----------------------------------------------------------------------------
+
+func TestWebServerWebSocket(t *testing.T) {
+	//Create server
+	srv, err := webserver.Create(webserver.Options{
+		Address: "127.0.0.1",
+		Port:    3000,
+		// Let the same-origin check use the host forwarded by the proxy
+		TrustedProxies: []string{"127.0.0.1"},
+		WebSocket: webserver.WebSocketOptions{
+			EnableCompression: true,
+			ReadLimit:         65536,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create web server [%v]", err)
+	}
+	srv.WebSocket("/ws/{room}", func(conn *webserver.WebSocketConn) {
+		prefix := conn.UserValue("room").(string) + ":" + conn.UserValue("user").(string) + ":"
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			err = conn.WriteMessage(msgType, append([]byte(prefix), msg...))
+			if err != nil {
+				return
+			}
+		}
+	}, func(req *webserver.RequestContext) error {
+		user := req.RequestHeader("X-User")
+		if len(user) == 0 {
+			req.Unauthorized("")
+			return nil
+		}
+		req.SetUserValue("user", user)
+		return req.Next()
+	})
+
+	err = srv.Start()
+	if err != nil {
+		t.Fatalf("unable to start web server [%v]", err)
+	}
+	defer srv.Stop()
+
+	headers := http.Header{}
+	headers.Set("X-User", "john")
+
+	// Plain requests must be rejected
+	_, _, _, err = testcommon.QueryPath(http.MethodGet, "/ws/lobby", headers, nil, []int{426})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+	}
+
+	// Middlewares must run before the upgrade
+	_, resp, err := dialer.Dial("ws://127.0.0.1:3000/ws/lobby", nil)
+	if err == nil || resp == nil || resp.StatusCode != 401 {
+		t.Fatalf("unauthorized connection was not rejected [%v]", err)
+	}
+
+	// Cross-origin requests must be rejected by default
+	badOriginHeaders := headers.Clone()
+	badOriginHeaders.Set("Origin", "http://evil.example.com")
+	_, resp, err = dialer.Dial("ws://127.0.0.1:3000/ws/lobby", badOriginHeaders)
+	if err == nil || resp == nil || resp.StatusCode != 403 {
+		t.Fatalf("cross-origin connection was not rejected [%v]", err)
+	}
+
+	// Same-origin requests behind a proxy must be accepted
+	proxiedHeaders := headers.Clone()
+	proxiedHeaders.Set("Origin", "https://public.example.com")
+	proxiedHeaders.Set("X-Forwarded-Host", "public.example.com")
+	proxiedConn, _, err := dialer.Dial("ws://127.0.0.1:3000/ws/lobby", proxiedHeaders)
+	if err != nil {
+		t.Fatalf("proxied same-origin connection was rejected [%v]", err)
+	}
+	_ = proxiedConn.Close()
+
+	// Connect and echo some messages
+	conn, resp, err := dialer.Dial("ws://127.0.0.1:3000/ws/lobby", headers)
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Fatalf("compression was not negotiated")
+	}
+
+	err = conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("hello", 100)))
+	if err == nil {
+		var msg []byte
+		_, msg, err = conn.ReadMessage()
+		if err == nil && string(msg) != "lobby:john:"+strings.Repeat("hello", 100) {
+			err = errors.New("unexpected message " + string(msg))
+		}
+	}
+	if err != nil {
+		t.Fatalf("unable to echo message [%v]", err)
+	}
+
+	// Messages bigger than the limit must be rejected, even if fragmented
+	conn2, _, err := dialer.Dial("ws://127.0.0.1:3000/ws/lobby", headers)
+	if err != nil {
+		t.Fatalf("unable to connect [%v]", err)
+	}
+	defer func() {
+		_ = conn2.Close()
+	}()
+	conn2.EnableWriteCompression(false)
+	w, err := conn2.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		t.Fatalf("unable to send message [%v]", err)
+	}
+	chunk := make([]byte, 16384)
+	for idx := 0; idx < 5 && err == nil; idx++ {
+		_, err = w.Write(chunk)
+	}
+	_ = w.Close()
+
+	_ = conn2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn2.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("message over the limit was not rejected [%v]", err)
+	}
+
+	// Stopping the server must send a close frame
+	stopDone := make(chan struct{})
+	go func() {
+		srv.Stop()
+		close(stopDone)
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("close frame was not received [%v]", err)
+	}
+	<-stopDone
+}
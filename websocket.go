@@ -0,0 +1,328 @@
+// See the LICENSE file for license details.
+
+package go_webserver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/valyala/fasthttp"
+)
+
+// -----------------------------------------------------------------------------
+
+// WebSocketHandler defines a function that handles a WebSocket connection. The connection is closed when the
+// function returns.
+type WebSocketHandler func(conn *WebSocketConn)
+
+// WebSocketOptions sets the parameters to use in WebSocket endpoints.
+type WebSocketOptions struct {
+	// AllowedOrigins is the list of origins allowed to open connections. Use "*" to allow any origin. If empty,
+	// only requests without an Origin header or with one that matches the request host are accepted.
+	AllowedOrigins []string
+
+	// CheckOrigin, if set, replaces the AllowedOrigins check.
+	CheckOrigin func(req *RequestContext) bool
+
+	// Subprotocols is the list of supported subprotocols in order of preference.
+	Subprotocols []string
+
+	// EnableCompression enables the negotiation of the permessage-deflate extension.
+	EnableCompression bool
+
+	// ReadLimit is the maximum size, in bytes, of a message read from the peer. Defaults to 1MB.
+	ReadLimit int64
+
+	// ReadBufferSize and WriteBufferSize specify the I/O buffer sizes. They do not limit the message size.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// PingInterval is the interval between the pings sent to the peer. If a pong is not received within two
+	// intervals, the connection is considered dead and reads fail. Defaults to 30 seconds. Set a negative value to
+	// disable them.
+	PingInterval time.Duration
+}
+
+// WebSocketConn is a WebSocket connection. It embeds a github.com/fasthttp/websocket connection, so use the
+// constants of that package to specify message types and close codes.
+//
+// As the handler runs after the request handler returns, the request values that can be used in it are
+// captured when the connection is upgraded.
+type WebSocketConn struct {
+	*websocket.Conn
+	ctx        context.Context
+	cancelCtx  context.CancelFunc
+	header     fasthttp.RequestHeader
+	remoteIP   net.IP
+	userValues map[any]any
+}
+
+type webSocketSet struct {
+	mtx     sync.Mutex
+	conns   map[*WebSocketConn]struct{}
+	wg      sync.WaitGroup
+	closing bool
+	mounted []*Server
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	defaultWebSocketReadLimit    = 1048576 // 1MB
+	defaultWebSocketPingInterval = 30 * time.Second
+
+	webSocketCloseTimeout = time.Second
+)
+
+// -----------------------------------------------------------------------------
+
+// WebSocket adds a GET route that upgrades the connection to the WebSocket protocol after running the middleware
+// chain, so middlewares like authorization or CORS apply to the handshake. Requests that are not upgrade
+// requests are rejected with a 426 error.
+//
+// Open connections are tracked and receive a "going away" close frame when the server is stopped.
+func (srv *Server) WebSocket(path string, handler WebSocketHandler, middlewares ...HandlerFunc) *Route {
+	return srv.defaultRoutes.webSocket(path, handler, nil, middlewares)
+}
+
+// Context returns a context that is canceled when the connection is closed or the server is being stopped. It
+// holds the values of the request's user context.
+func (conn *WebSocketConn) Context() context.Context {
+	return conn.ctx
+}
+
+// RequestHeader returns the value of a header of the upgrade request.
+func (conn *WebSocketConn) RequestHeader(key string) string {
+	return string(conn.header.Peek(key))
+}
+
+// RemoteIP returns the client IP address. See RequestContext.RemoteIP for details.
+func (conn *WebSocketConn) RemoteIP() net.IP {
+	return conn.remoteIP
+}
+
+// UserValue returns the value stored in the request with the specified key, including the route parameters.
+func (conn *WebSocketConn) UserValue(key any) any {
+	return conn.userValues[key]
+}
+
+// CloseWithCode sends a close frame with the specified code and reason. The peer is expected to reply with its
+// own close frame, which makes the next read return a *websocket.CloseError.
+func (conn *WebSocketConn) CloseWithCode(code int, reason string) error {
+	msg := websocket.FormatCloseMessage(code, reason)
+	return conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(webSocketCloseTimeout))
+}
+
+// -----------------------------------------------------------------------------
+
+func (rt *routeTable) webSocket(
+	path string, handler WebSocketHandler, groupMiddlewares []HandlerFunc, middlewares []HandlerFunc,
+) *Route {
+	if handler == nil {
+		panic("invalid websocket handler")
+	}
+	srv := rt.srv
+
+	return rt.addRoute(fasthttp.MethodGet, path, func(req *RequestContext) error {
+		return srv.upgradeWebSocket(req, handler)
+	}, groupMiddlewares, middlewares)
+}
+
+func (srv *Server) upgradeWebSocket(req *RequestContext, handler WebSocketHandler) error {
+	opts := &srv.webSocketOpts
+
+	if !websocket.FastHTTPIsWebSocketUpgrade(req.ctx) {
+		req.ctx.Response.Header.Set(fasthttp.HeaderUpgrade, "websocket")
+		req.ctx.Response.Header.Set("Sec-WebSocket-Version", "13")
+		req.sendError(fasthttp.StatusUpgradeRequired, "")
+		return nil
+	}
+
+	upgrader := websocket.FastHTTPUpgrader{
+		HandshakeTimeout:  srv.fastserver.ReadTimeout,
+		ReadBufferSize:    opts.ReadBufferSize,
+		WriteBufferSize:   opts.WriteBufferSize,
+		Subprotocols:      opts.Subprotocols,
+		EnableCompression: opts.EnableCompression,
+		Error: func(_ *fasthttp.RequestCtx, status int, _ error) {
+			req.ctx.Response.Header.Set("Sec-WebSocket-Version", "13")
+			req.sendError(status, "")
+		},
+		CheckOrigin: func(_ *fasthttp.RequestCtx) bool {
+			if opts.CheckOrigin != nil {
+				return opts.CheckOrigin(req)
+			}
+			return isWebSocketOriginAllowed(req, opts.AllowedOrigins)
+		},
+	}
+
+	// Capture the request values because the request context is recycled before the handler runs
+	conn := &WebSocketConn{
+		remoteIP:   req.RemoteIP(),
+		userValues: make(map[any]any),
+	}
+	conn.ctx, conn.cancelCtx = context.WithCancel(context.WithoutCancel(req.UserContext()))
+	req.ctx.Request.Header.CopyTo(&conn.header)
+	req.ctx.VisitUserValuesAll(func(key any, value any) {
+		if key != reqContextLinkKey {
+			conn.userValues[key] = value
+		}
+	})
+
+	err := upgrader.Upgrade(req.ctx, func(c *websocket.Conn) {
+		conn.Conn = c
+		srv.serveWebSocket(conn, handler)
+	})
+	if err != nil {
+		// The upgrader already sent the error response
+		conn.cancelCtx()
+	}
+
+	// Done
+	return nil
+}
+
+func (srv *Server) serveWebSocket(conn *WebSocketConn, handler WebSocketHandler) {
+	defer conn.cancelCtx()
+
+	if !srv.webSockets.add(conn) {
+		_ = conn.CloseWithCode(websocket.CloseGoingAway, "server is shutting down")
+		return
+	}
+	defer srv.webSockets.remove(conn)
+
+	readLimit := srv.webSocketOpts.ReadLimit
+	if readLimit == 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+	conn.SetReadLimit(readLimit)
+
+	pingInterval := srv.webSocketOpts.PingInterval
+	if pingInterval == 0 {
+		pingInterval = defaultWebSocketPingInterval
+	}
+	if pingInterval > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		})
+		go conn.keepAlive(pingInterval)
+	}
+
+	handler(conn)
+}
+
+func (conn *WebSocketConn) keepAlive(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+
+		case <-ticker.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketCloseTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func isWebSocketOriginAllowed(req *RequestContext, allowedOrigins []string) bool {
+	origin := string(req.ctx.Request.Header.Peek(fasthttp.HeaderOrigin))
+	if len(origin) == 0 {
+		return true
+	}
+
+	if len(allowedOrigins) == 0 {
+		// Same origin
+		u := fasthttp.AcquireURI()
+		defer fasthttp.ReleaseURI(u)
+
+		if u.Parse(nil, []byte(origin)) != nil {
+			return false
+		}
+		return strings.EqualFold(string(u.Host()), req.Host())
+	}
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+func (ws *webSocketSet) add(conn *WebSocketConn) bool {
+	ws.mtx.Lock()
+	defer ws.mtx.Unlock()
+
+	if ws.closing {
+		return false
+	}
+	if ws.conns == nil {
+		ws.conns = make(map[*WebSocketConn]struct{})
+	}
+	ws.conns[conn] = struct{}{}
+	ws.wg.Add(1)
+	return true
+}
+
+func (ws *webSocketSet) remove(conn *WebSocketConn) {
+	ws.mtx.Lock()
+	delete(ws.conns, conn)
+	ws.mtx.Unlock()
+
+	ws.wg.Done()
+}
+
+func (ws *webSocketSet) addMounted(sub *Server) {
+	ws.mtx.Lock()
+	ws.mounted = append(ws.mounted, sub)
+	ws.mtx.Unlock()
+}
+
+// closeAll sends a close frame to all the open connections, including the ones of mounted servers, and waits
+// until their handlers return or the context is done. Then, the remaining connections are closed. New connections
+// are rejected from now on.
+func (ws *webSocketSet) closeAll(ctx context.Context) {
+	ws.mtx.Lock()
+	ws.closing = true
+	conns := make([]*WebSocketConn, 0, len(ws.conns))
+	for conn := range ws.conns {
+		conns = append(conns, conn)
+	}
+	mounted := ws.mounted
+	ws.mtx.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.CloseWithCode(websocket.CloseGoingAway, "server is shutting down")
+		conn.cancelCtx()
+	}
+
+	for _, sub := range mounted {
+		sub.webSockets.closeAll(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ws.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}
+}